@@ -1,9 +1,11 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"os"
+	"time"
 
 	"contrib.go.opencensus.io/exporter/jaeger"
 	"contrib.go.opencensus.io/exporter/prometheus"
@@ -16,7 +18,9 @@ import (
 	"go.opencensus.io/trace"
 
 	"github.com/sagikazarmark/go-gin-gorm-opencensus/internal"
+	"github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgin"
 	"github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgorm"
+	ocgormmysql "github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgorm/dialects/mysql"
 )
 
 func main() {
@@ -31,23 +35,26 @@ func main() {
 	// Register prometheus as a stats exporter
 	view.RegisterExporter(pe)
 
-	// Register stat views
+	// Register Gin (HTTP) stat views
 	err = view.Register(
-		// Gin (HTTP) stats
 		ochttp.ServerRequestCountView,
 		ochttp.ServerRequestBytesView,
 		ochttp.ServerResponseBytesView,
 		ochttp.ServerLatencyView,
 		ochttp.ServerRequestCountByMethod,
 		ochttp.ServerResponseCountByStatusCode,
-
-		// Gorm stats
-		ocgorm.QueryCountView,
+		ocgin.ServerLatencyByStatusView,
 	)
 	if err != nil {
 		panic(err)
 	}
 
+	// Register Gorm stat views broken down by HTTP route and health check views
+	err = view.Register(append(ocgorm.RouteViews, ocgin.HealthViews...)...)
+	if err != nil {
+		panic(err)
+	}
+
 	// Always trace for this demo. In a production application, you should
 	// configure this to a trace.ProbabilitySampler set at the desired
 	// probability.
@@ -82,8 +89,16 @@ func main() {
 		panic(err)
 	}
 
-	// Register instrumentation callbacks
-	ocgorm.RegisterCallbacks(db)
+	// Register instrumentation callbacks and record connection pool stats
+	stopStats, err := ocgorm.Instrument(
+		db,
+		ocgorm.ErrorClassifiers(ocgormmysql.ClassifyError),
+		ocgorm.StatsInterval(5*time.Second),
+	)
+	if err != nil {
+		panic(err)
+	}
+	defer stopStats()
 
 	// Run migrations and fixtures
 	db.AutoMigrate(internal.Person{})
@@ -99,19 +114,19 @@ func main() {
 		pe.ServeHTTP(c.Writer, c.Request)
 	}))
 
+	r.GET(ocgin.HealthPath, ocgin.NamedHealthHandler(map[string]func(ctx context.Context) error{
+		"db": ocgorm.DBCheck(db),
+	}))
+
 	// Add routes
 	r.POST(
 		"/people",
-		func(c *gin.Context) {
-			ochttp.SetRoute(c.Request.Context(), "/people")
-		},
+		ocgorm.Route("/people"),
 		internal.CreatePerson(db),
 	)
 	r.GET(
 		"/hello/:firstName",
-		func(c *gin.Context) {
-			ochttp.SetRoute(c.Request.Context(), "/hello/:firstName")
-		},
+		ocgorm.Route("/hello/:firstName"),
 		internal.Hello(db),
 	)
 
@@ -125,7 +140,7 @@ func main() {
 			GetStartOptions: func(r *http.Request) trace.StartOptions {
 				startOptions := trace.StartOptions{}
 
-				if r.URL.Path == "/metrics" {
+				if r.URL.Path == "/metrics" || ocgin.IsHealthRequest(r) {
 					startOptions.Sampler = trace.NeverSample()
 				}
 
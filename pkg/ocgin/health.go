@@ -0,0 +1,172 @@
+// Package ocgin provides OpenCensus instrumentation helpers for gin servers.
+package ocgin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+
+	"github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgorm"
+)
+
+// Health check paths.
+// ochttp.Handler neither traces nor records stats for requests to these paths.
+const (
+	// HealthPath is the canonical path of health check endpoints (see HealthHandler).
+	HealthPath = "/healthz"
+
+	// AppEngineHealthPath is the path of the health checks of Google App Engine.
+	AppEngineHealthPath = "/_ah/health"
+)
+
+// healthCheckTimeout is the timeout of the checks run by HealthHandler.
+const healthCheckTimeout = 2 * time.Second
+
+// MeasureHealthCheckUp is the result of the last run of a health check (see HealthHandler).
+var MeasureHealthCheckUp = stats.Int64("health/check/up", "Whether the health check passed (1) or not (0)", stats.UnitDimensionless)
+
+// HealthCheck is the name of the health check (see HealthHandler).
+var HealthCheck, _ = tag.NewKey("health.check")
+
+// HealthCheckUpView is the availability of each health check.
+var HealthCheckUpView = &view.View{
+	Name:        "health/check/up",
+	Description: "Whether the health check passed (1) or not (0)",
+	Measure:     MeasureHealthCheckUp,
+	TagKeys:     []tag.Key{HealthCheck},
+	Aggregation: view.LastValue(),
+}
+
+// HealthViews contains the views recommended to register for health checks (see HealthHandler).
+var HealthViews = []*view.View{
+	HealthCheckUpView,
+}
+
+// IsHealthRequest reports whether a request is a health check (see HealthPath and AppEngineHealthPath).
+//
+// It can be used to exclude health checks from tracing
+// when they are served behind a custom handler (eg. in ochttp.Handler.GetStartOptions).
+func IsHealthRequest(r *http.Request) bool {
+	return r.URL.Path == HealthPath || r.URL.Path == AppEngineHealthPath
+}
+
+type healthCheckResult struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// HealthHandler returns a gin handler running the health checks (eg. ocgorm.DBCheck) with a short timeout.
+//
+// The handler responds with 200 if every check passes, 503 otherwise,
+// with the result of each check (keyed by name) in the JSON body.
+// The result of each check is recorded in MeasureHealthCheckUp (see HealthViews).
+// Checks are named after their position in the arguments (starting from 0),
+// use NamedHealthHandler to give them meaningful names.
+//
+// Checks are excluded from tracing and query stats by construction:
+// ocgorm does not record spans or stats for them (see ocgorm.WithHealthCheck).
+// Register the handler on HealthPath (or see IsHealthRequest) to exclude the request itself as well.
+func HealthHandler(checks ...func(ctx context.Context) error) gin.HandlerFunc {
+	names := make([]string, len(checks))
+	for i := range checks {
+		names[i] = strconv.Itoa(i)
+	}
+
+	return healthHandler(names, checks)
+}
+
+// NamedHealthHandler returns a gin handler running the health checks keyed by their names (see HealthHandler).
+func NamedHealthHandler(checks map[string]func(ctx context.Context) error) gin.HandlerFunc {
+	names := make([]string, 0, len(checks))
+	for name := range checks {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	fns := make([]func(ctx context.Context) error, len(names))
+	for i, name := range names {
+		fns[i] = checks[name]
+	}
+
+	return healthHandler(names, fns)
+}
+
+// healthHandler returns a gin handler running the checks, each named by the name at the same index.
+func healthHandler(names []string, checks []func(ctx context.Context) error) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), healthCheckTimeout)
+		defer cancel()
+
+		ctx = ocgorm.WithHealthCheck(ctx)
+
+		errs := make([]error, len(checks))
+
+		var wg sync.WaitGroup
+
+		for i, check := range checks {
+			wg.Add(1)
+
+			go func(i int, check func(ctx context.Context) error) {
+				defer wg.Done()
+
+				errs[i] = runHealthCheck(ctx, check)
+			}(i, check)
+		}
+
+		wg.Wait()
+
+		code := http.StatusOK
+		results := make(map[string]healthCheckResult, len(names))
+
+		for i, name := range names {
+			var up int64 = 1
+
+			result := healthCheckResult{Status: "ok"}
+
+			if errs[i] != nil {
+				up = 0
+				code = http.StatusServiceUnavailable
+
+				result = healthCheckResult{Status: "error", Error: errs[i].Error()}
+			}
+
+			results[name] = result
+
+			_ = stats.RecordWithTags(ctx, []tag.Mutator{tag.Upsert(HealthCheck, name)}, MeasureHealthCheckUp.M(up))
+		}
+
+		status := "ok"
+		if code != http.StatusOK {
+			status = "unavailable"
+		}
+
+		c.JSON(code, gin.H{
+			"status": status,
+			"checks": results,
+		})
+	}
+}
+
+// errHealthCheckPanic is returned for health checks that panicked.
+var errHealthCheckPanic = errors.New("health check panicked")
+
+// runHealthCheck runs a check, reporting panics as failures.
+func runHealthCheck(ctx context.Context, check func(ctx context.Context) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errHealthCheckPanic
+		}
+	}()
+
+	return check(ctx)
+}
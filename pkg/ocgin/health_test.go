@@ -0,0 +1,158 @@
+package ocgin_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"go.opencensus.io/trace"
+
+	"github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgin"
+	"github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgorm"
+	"github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgorm/ocgormtest"
+)
+
+func TestMain(m *testing.M) {
+	trace.ApplyConfig(trace.Config{DefaultSampler: trace.AlwaysSample()})
+	gin.SetMode(gin.ReleaseMode)
+
+	os.Exit(m.Run())
+}
+
+func TestIsHealthRequest(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{"/healthz", true},
+		{"/_ah/health", true},
+		{"/healthz/db", false},
+		{"/people", false},
+	}
+
+	for _, test := range tests {
+		if actual := ocgin.IsHealthRequest(httptest.NewRequest(http.MethodGet, test.path, nil)); actual != test.expected {
+			t.Errorf("%s: expected %t, got %t", test.path, test.expected, actual)
+		}
+	}
+}
+
+type healthResponse struct {
+	Status string `json:"status"`
+	Checks map[string]struct {
+		Status string `json:"status"`
+		Error  string `json:"error"`
+	} `json:"checks"`
+}
+
+func serveHealth(t *testing.T, handler gin.HandlerFunc) (int, healthResponse) {
+	t.Helper()
+
+	r := gin.New()
+	r.GET(ocgin.HealthPath, handler)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, ocgin.HealthPath, nil))
+
+	var response healthResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+
+	return w.Code, response
+}
+
+func healthCheckUp(t *testing.T, name string) float64 {
+	t.Helper()
+
+	rows, err := ocgormtest.ViewRows(ocgin.HealthCheckUpView, tag.Tag{Key: ocgin.HealthCheck, Value: name})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) != 1 {
+		t.Fatalf("expected one row for check %q, got %d", name, len(rows))
+	}
+
+	return rows[0].Data.(*view.LastValueData).Value
+}
+
+func TestHealthHandler(t *testing.T) {
+	if err := view.Register(ocgin.HealthViews...); err != nil {
+		t.Fatal(err)
+	}
+	defer view.Unregister(ocgin.HealthViews...)
+
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	ocgorm.RegisterCallbacks(db)
+
+	exporter, unregister := ocgormtest.NewExporter()
+	defer unregister()
+
+	code, response := serveHealth(t, ocgin.HealthHandler(
+		ocgorm.DBCheck(db),
+		func(ctx context.Context) error { return errors.New("unavailable") },
+		func(ctx context.Context) error { panic("check") },
+	))
+
+	if code != http.StatusServiceUnavailable || response.Status != "unavailable" {
+		t.Errorf("expected the service to be unavailable, got %d: %s", code, response.Status)
+	}
+
+	expected := map[string]string{"0": "ok", "1": "error", "2": "error"}
+
+	for name, status := range expected {
+		if actual := response.Checks[name].Status; actual != status {
+			t.Errorf("check %s: expected status %q, got %q", name, status, actual)
+		}
+	}
+
+	if up := healthCheckUp(t, "0"); up != 1 {
+		t.Errorf("expected the database check to be up, got %f", up)
+	}
+
+	if up := healthCheckUp(t, "1"); up != 0 {
+		t.Errorf("expected the failing check to be down, got %f", up)
+	}
+
+	if spans := exporter.SpansWithName("gorm:ping"); len(spans) != 0 {
+		t.Errorf("health checks must not be traced, got %d spans", len(spans))
+	}
+}
+
+func TestNamedHealthHandler(t *testing.T) {
+	if err := view.Register(ocgin.HealthViews...); err != nil {
+		t.Fatal(err)
+	}
+	defer view.Unregister(ocgin.HealthViews...)
+
+	code, response := serveHealth(t, ocgin.NamedHealthHandler(map[string]func(ctx context.Context) error{
+		"cache": func(ctx context.Context) error { return nil },
+	}))
+
+	if code != http.StatusOK || response.Status != "ok" {
+		t.Errorf("expected the service to be healthy, got %d: %s", code, response.Status)
+	}
+
+	if actual := response.Checks["cache"].Status; actual != "ok" {
+		t.Errorf("expected the check to pass, got %q", actual)
+	}
+
+	if up := healthCheckUp(t, "cache"); up != 1 {
+		t.Errorf("expected the check to be up, got %f", up)
+	}
+}
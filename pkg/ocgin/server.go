@@ -0,0 +1,266 @@
+package ocgin
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opencensus.io/trace"
+)
+
+// Attributes recorded on the server span.
+const (
+	// HTTPClientIPAttribute is the IP address of the client (see the ClientIPAttribute option).
+	HTTPClientIPAttribute = "http.client_ip"
+
+	// ForceEndedAttribute is recorded on server spans ended before the request completed (see the MaxSpanDuration option).
+	ForceEndedAttribute = "ocgin.force_ended"
+)
+
+// Option allows for managing the server span instrumentation of Middleware using functional options.
+//
+// The server span is the one started by ochttp.Handler for the request.
+type Option interface {
+	apply(c *config)
+}
+
+// OptionFunc converts a regular function to an Option if it's definition is compatible.
+type OptionFunc func(c *config)
+
+func (fn OptionFunc) apply(c *config) {
+	fn(c)
+}
+
+// ClientIPAttribute records the IP address of the client (see gin.Context.ClientIP) in the server span.
+// Values that are not valid IP addresses (eg. spoofed X-Forwarded-For headers) are not recorded.
+type ClientIPAttribute bool
+
+func (a ClientIPAttribute) apply(c *config) {
+	c.clientIP = bool(a)
+}
+
+// AnonymizeIP truncates the client IP addresses recorded by ClientIPAttribute:
+// the last octet of IPv4 addresses is zeroed, only the /48 prefix of IPv6 addresses is kept.
+type AnonymizeIP bool
+
+func (a AnonymizeIP) apply(c *config) {
+	if a {
+		c.anonymizeIP = anonymizeIP
+	} else {
+		c.anonymizeIP = nil
+	}
+}
+
+// AnonymizeIPFunc sets a function anonymizing the client IP addresses recorded by ClientIPAttribute.
+// It is only called with valid IP addresses. Returning an empty string drops the address.
+func AnonymizeIPFunc(fn func(ip net.IP) string) Option {
+	return OptionFunc(func(c *config) {
+		c.anonymizeIP = fn
+	})
+}
+
+// LongRequestHeartbeat annotates the server span of requests running longer than the interval
+// periodically (at each interval) until the request ends,
+// so that long running requests (eg. long polling) are visible before their span ends.
+//
+// Timers are started lazily: no goroutine runs for requests finishing within the interval.
+type LongRequestHeartbeat time.Duration
+
+func (l LongRequestHeartbeat) apply(c *config) {
+	c.heartbeat = time.Duration(l)
+}
+
+// MaxSpanDuration force-ends the server span of requests still running after the duration
+// (eg. stuck handlers, hijacked connections) with a DeadlineExceeded status and an ocgin.force_ended attribute.
+//
+// The request itself continues: its stats are recorded when it actually completes.
+type MaxSpanDuration time.Duration
+
+func (m MaxSpanDuration) apply(c *config) {
+	c.maxSpanDuration = time.Duration(m)
+}
+
+type config struct {
+	// Record the client IP address in the server span.
+	clientIP bool
+
+	// anonymizeIP anonymizes the recorded client IP addresses.
+	anonymizeIP func(ip net.IP) string
+
+	// Interval of the heartbeat annotations of long running requests.
+	// Zero disables heartbeats.
+	heartbeat time.Duration
+
+	// Duration after which the server span is force-ended.
+	// Zero means no limit.
+	maxSpanDuration time.Duration
+}
+
+// newConfig returns a new middleware configuration with the options applied.
+func newConfig(opts ...Option) *config {
+	c := &config{}
+
+	for _, opt := range opts {
+		opt.apply(c)
+	}
+
+	return c
+}
+
+// Middleware returns a gin middleware instrumenting the server span of the request
+// started by ochttp.Handler according to the options (eg. ClientIPAttribute, LongRequestHeartbeat).
+//
+// Register it before the handlers: timers (see LongRequestHeartbeat and MaxSpanDuration) are stopped when the handlers return.
+// Requests without a server span are left untouched.
+func Middleware(opts ...Option) gin.HandlerFunc {
+	mc := newConfig(opts...)
+
+	return func(c *gin.Context) {
+		span := trace.FromContext(c.Request.Context())
+		if span == nil {
+			return
+		}
+
+		if mc.clientIP {
+			if ip := mc.clientIPAttribute(c.ClientIP()); ip != "" {
+				span.AddAttributes(trace.StringAttribute(HTTPClientIPAttribute, ip))
+			}
+		}
+
+		timers := mc.startSpanTimers(span)
+		defer timers.stop()
+
+		c.Next()
+	}
+}
+
+// clientIPAttribute returns the client IP address recorded in the server span (empty if it should not be recorded).
+func (c *config) clientIPAttribute(clientIP string) string {
+	ip := net.ParseIP(clientIP)
+	if ip == nil {
+		return ""
+	}
+
+	if c.anonymizeIP != nil {
+		return c.anonymizeIP(ip)
+	}
+
+	return ip.String()
+}
+
+// ipv6AnonymizationMask keeps the /48 prefix of IPv6 addresses.
+var ipv6AnonymizationMask = net.CIDRMask(48, 128)
+
+// anonymizeIP zeroes the last octet of IPv4 addresses and keeps the /48 prefix of IPv6 addresses.
+func anonymizeIP(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return net.IPv4(ip4[0], ip4[1], ip4[2], 0).String()
+	}
+
+	return ip.Mask(ipv6AnonymizationMask).String()
+}
+
+// spanTimers runs the timers of a server span, making sure they never fire after the request ends.
+type spanTimers struct {
+	mu    sync.Mutex
+	span  *trace.Span
+	start time.Time
+	ended bool
+
+	heartbeatInterval time.Duration
+	heartbeat         *time.Timer
+
+	deadline *time.Timer
+}
+
+// startSpanTimers starts the timers of a server span according to the configuration.
+// It returns nil if there is nothing to do.
+func (c *config) startSpanTimers(span *trace.Span) *spanTimers {
+	if c.heartbeat <= 0 && c.maxSpanDuration <= 0 {
+		return nil
+	}
+
+	t := &spanTimers{
+		span:              span,
+		start:             time.Now(),
+		heartbeatInterval: c.heartbeat,
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.heartbeatInterval > 0 {
+		t.heartbeat = time.AfterFunc(t.heartbeatInterval, t.beat)
+	}
+
+	if c.maxSpanDuration > 0 {
+		t.deadline = time.AfterFunc(c.maxSpanDuration, t.forceEnd)
+	}
+
+	return t
+}
+
+// beat annotates the span with the elapsed time and schedules the next heartbeat.
+func (t *spanTimers) beat() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.ended {
+		return
+	}
+
+	elapsed := time.Since(t.start).Round(time.Second)
+
+	t.span.Annotate(
+		[]trace.Attribute{trace.StringAttribute("elapsed", elapsed.String())},
+		"still processing, elapsed="+elapsed.String(),
+	)
+
+	t.heartbeat.Reset(t.heartbeatInterval)
+}
+
+// forceEnd ends the span of a request running longer than the maximum span duration.
+//
+// The span is ended again when the request completes (eg. by ochttp.Handler), but spans can only be ended once.
+func (t *spanTimers) forceEnd() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.ended {
+		return
+	}
+
+	t.ended = true
+
+	if t.heartbeat != nil {
+		t.heartbeat.Stop()
+	}
+
+	t.span.AddAttributes(trace.BoolAttribute(ForceEndedAttribute, true))
+	t.span.SetStatus(trace.Status{
+		Code:    trace.StatusCodeDeadlineExceeded,
+		Message: "span exceeded the maximum duration",
+	})
+	t.span.End()
+}
+
+// stop stops the timers when the request ends.
+func (t *spanTimers) stop() {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.ended = true
+
+	if t.heartbeat != nil {
+		t.heartbeat.Stop()
+	}
+
+	if t.deadline != nil {
+		t.deadline.Stop()
+	}
+}
@@ -0,0 +1,274 @@
+package ocgin
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/trace"
+
+	"github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgorm/ocgormtest"
+)
+
+func TestConfig_ClientIPAttribute(t *testing.T) {
+	tests := []struct {
+		name      string
+		clientIP  string
+		anonymize bool
+		expected  string
+	}{
+		{"ipv4", "192.168.1.42", false, "192.168.1.42"},
+		{"ipv4 anonymized", "192.168.1.42", true, "192.168.1.0"},
+		{"ipv4 mapped ipv6 anonymized", "::ffff:10.1.2.3", true, "10.1.2.0"},
+		{"ipv6", "2001:db8:85a3:8d3:1319:8a2e:370:7348", false, "2001:db8:85a3:8d3:1319:8a2e:370:7348"},
+		{"ipv6 anonymized", "2001:db8:85a3:8d3:1319:8a2e:370:7348", true, "2001:db8:85a3::"},
+		{"ipv6 loopback anonymized", "::1", true, "::"},
+		{"empty", "", true, ""},
+		{"spoofed header", "<script>alert(1)</script>", true, ""},
+		{"multiple addresses", "10.0.0.1, 10.0.0.2", true, ""},
+		{"address with port", "10.0.0.1:8080", true, ""},
+		{"out of range octet", "256.1.1.1", false, ""},
+		{"ipv6 zone", "fe80::1%eth0", true, ""},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			c := newConfig(ClientIPAttribute(true), AnonymizeIP(test.anonymize))
+
+			if actual := c.clientIPAttribute(test.clientIP); actual != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestAnonymizeIPFunc(t *testing.T) {
+	var called bool
+
+	c := newConfig(AnonymizeIPFunc(func(ip net.IP) string {
+		called = true
+
+		return "anonymous"
+	}))
+
+	if actual := c.clientIPAttribute("not an ip"); actual != "" || called {
+		t.Errorf("malformed addresses must not be passed to the custom function, got %q", actual)
+	}
+
+	if actual := c.clientIPAttribute("10.0.0.1"); actual != "anonymous" {
+		t.Errorf("expected the result of the custom function, got %q", actual)
+	}
+}
+
+func TestMiddleware_ClientIPAttribute(t *testing.T) {
+	exporter, unregister := ocgormtest.NewExporter()
+	defer unregister()
+
+	r := gin.New()
+	r.Use(Middleware(ClientIPAttribute(true), AnonymizeIP(true)))
+	r.GET("/people", func(c *gin.Context) {})
+
+	req := httptest.NewRequest(http.MethodGet, "/people", nil)
+	req.RemoteAddr = "192.168.1.42:54321"
+
+	handler := &ochttp.Handler{Handler: r}
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := exporter.SpansWithName("/people")
+	if len(spans) != 1 {
+		t.Fatalf("expected one server span, got %d", len(spans))
+	}
+
+	if actual := spans[0].Attributes[HTTPClientIPAttribute]; actual != "192.168.1.0" {
+		t.Errorf("expected the anonymized client IP, got %v", actual)
+	}
+}
+
+func TestLongRequestHeartbeat(t *testing.T) {
+	exporter, unregister := ocgormtest.NewExporter()
+	defer unregister()
+
+	_, span := trace.StartSpan(context.Background(), "request", trace.WithSampler(trace.AlwaysSample()))
+
+	c := newConfig(LongRequestHeartbeat(10 * time.Millisecond))
+
+	timers := c.startSpanTimers(span)
+
+	time.Sleep(55 * time.Millisecond)
+
+	timers.stop()
+
+	// Heartbeats would keep annotating the span if the timer was not stopped
+	time.Sleep(50 * time.Millisecond)
+
+	span.End()
+
+	spans := exporter.Spans()
+	if len(spans) != 1 {
+		t.Fatalf("expected one exported span, got %d", len(spans))
+	}
+
+	annotations := len(spans[0].Annotations)
+	if annotations < 2 || annotations > 6 {
+		t.Errorf("expected heartbeat annotations until the request ended only, got %d", annotations)
+	}
+}
+
+func TestLongRequestHeartbeat_FastRequest(t *testing.T) {
+	exporter, unregister := ocgormtest.NewExporter()
+	defer unregister()
+
+	_, span := trace.StartSpan(context.Background(), "request", trace.WithSampler(trace.AlwaysSample()))
+
+	c := newConfig(LongRequestHeartbeat(20 * time.Millisecond))
+
+	timers := c.startSpanTimers(span)
+	timers.stop()
+
+	time.Sleep(40 * time.Millisecond)
+
+	span.End()
+
+	spans := exporter.Spans()
+	if len(spans) != 1 {
+		t.Fatalf("expected one exported span, got %d", len(spans))
+	}
+
+	if actual := len(spans[0].Annotations); actual != 0 {
+		t.Errorf("expected no heartbeat annotations, got %d", actual)
+	}
+}
+
+func TestMaxSpanDuration(t *testing.T) {
+	exporter, unregister := ocgormtest.NewExporter()
+	defer unregister()
+
+	_, span := trace.StartSpan(context.Background(), "request", trace.WithSampler(trace.AlwaysSample()))
+
+	c := newConfig(MaxSpanDuration(10*time.Millisecond), LongRequestHeartbeat(5*time.Millisecond))
+
+	timers := c.startSpanTimers(span)
+
+	time.Sleep(40 * time.Millisecond)
+
+	spans := exporter.Spans()
+	if len(spans) != 1 {
+		t.Fatalf("expected the span to be force-ended while the request is running, got %d spans", len(spans))
+	}
+
+	if spans[0].Attributes[ForceEndedAttribute] != true {
+		t.Errorf("expected the %s attribute", ForceEndedAttribute)
+	}
+
+	if spans[0].Status.Code != trace.StatusCodeDeadlineExceeded {
+		t.Errorf("expected status code %d, got %d", trace.StatusCodeDeadlineExceeded, spans[0].Status.Code)
+	}
+
+	// The request completes later
+	timers.stop()
+	span.End()
+
+	if actual := len(exporter.Spans()); actual != 1 {
+		t.Errorf("expected the span to be exported once, got %d", actual)
+	}
+}
+
+func TestMaxSpanDuration_FastRequest(t *testing.T) {
+	exporter, unregister := ocgormtest.NewExporter()
+	defer unregister()
+
+	_, span := trace.StartSpan(context.Background(), "request", trace.WithSampler(trace.AlwaysSample()))
+
+	c := newConfig(MaxSpanDuration(20 * time.Millisecond))
+
+	timers := c.startSpanTimers(span)
+	timers.stop()
+	span.End()
+
+	time.Sleep(40 * time.Millisecond)
+
+	spans := exporter.Spans()
+	if len(spans) != 1 {
+		t.Fatalf("expected one exported span, got %d", len(spans))
+	}
+
+	if _, ok := spans[0].Attributes[ForceEndedAttribute]; ok {
+		t.Errorf("unexpected %s attribute", ForceEndedAttribute)
+	}
+}
+
+func TestMiddleware_MaxSpanDuration(t *testing.T) {
+	if err := view.Register(ochttp.ServerLatencyView); err != nil {
+		t.Fatal(err)
+	}
+	defer view.Unregister(ochttp.ServerLatencyView)
+
+	exporter, unregister := ocgormtest.NewExporter()
+	defer unregister()
+
+	release := make(chan struct{})
+
+	r := gin.New()
+	r.Use(Middleware(MaxSpanDuration(10 * time.Millisecond)))
+	r.GET("/reports", func(c *gin.Context) {
+		<-release
+	})
+
+	handler := &ochttp.Handler{Handler: r}
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/reports", nil))
+	}()
+
+	var spans []*trace.SpanData
+
+	for deadline := time.Now().Add(time.Second); len(spans) == 0 && time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		spans = exporter.SpansWithName("/reports")
+	}
+
+	if len(spans) != 1 {
+		close(release)
+		t.Fatalf("expected the span to be force-ended while the request is running, got %d spans", len(spans))
+	}
+
+	if spans[0].Attributes[ForceEndedAttribute] != true {
+		t.Errorf("expected the %s attribute", ForceEndedAttribute)
+	}
+
+	rows, err := view.RetrieveData(ochttp.ServerLatencyView.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) != 0 {
+		t.Errorf("expected no stats before the request completes, got %d rows", len(rows))
+	}
+
+	close(release)
+	<-done
+
+	rows, err = view.RetrieveData(ochttp.ServerLatencyView.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) != 1 {
+		t.Errorf("expected the stats to be recorded when the request completes, got %d rows", len(rows))
+	}
+
+	if actual := len(exporter.SpansWithName("/reports")); actual != 1 {
+		t.Errorf("expected the span to be exported once, got %d", actual)
+	}
+}
@@ -0,0 +1,27 @@
+package ocgin
+
+import (
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+// ServerLatencyByStatusView is the distribution of server latencies by HTTP status code and route
+// (eg. to compare the latency of 5xx and 2xx responses of each route).
+// The route is recorded by ocgorm.Route (or ochttp.SetRoute).
+var ServerLatencyByStatusView = &view.View{
+	Name:        "opencensus.io/http/server/latency_by_status_code",
+	Description: "Latency distribution of HTTP requests by status code and route",
+	Measure:     ochttp.ServerLatency,
+	TagKeys:     []tag.Key{ochttp.StatusCode, ochttp.KeyServerRoute},
+	Aggregation: ochttp.DefaultLatencyDistribution,
+}
+
+// DefaultServerViews contains the views recommended to register for HTTP server stats (see ochttp.DefaultServerViews).
+var DefaultServerViews = append([]*view.View{}, ochttp.DefaultServerViews...)
+
+// ExtendedServerViews contains optional, more expensive HTTP server views
+// (eg. ones broken down by route) registered in addition to DefaultServerViews.
+var ExtendedServerViews = []*view.View{
+	ServerLatencyByStatusView,
+}
@@ -0,0 +1,59 @@
+package ocgin_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+
+	"github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgin"
+	"github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgorm"
+	"github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgorm/ocgormtest"
+)
+
+func TestExtendedServerViews(t *testing.T) {
+	if err := view.Register(ocgin.ExtendedServerViews...); err != nil {
+		t.Fatal(err)
+	}
+	defer view.Unregister(ocgin.ExtendedServerViews...)
+
+	r := gin.New()
+	r.GET("/people/:id", ocgorm.Route("/people/:id"), func(c *gin.Context) {
+		if c.Param("id") == "0" {
+			c.Status(http.StatusInternalServerError)
+
+			return
+		}
+
+		c.Status(http.StatusOK)
+	})
+
+	handler := &ochttp.Handler{Handler: r}
+
+	for _, path := range []string{"/people/1", "/people/0"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	for _, code := range []string{"200", "500"} {
+		rows, err := ocgormtest.ViewRows(
+			ocgin.ServerLatencyByStatusView,
+			tag.Tag{Key: ochttp.StatusCode, Value: code},
+			tag.Tag{Key: ochttp.KeyServerRoute, Value: "/people/:id"},
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(rows) != 1 {
+			t.Fatalf("status code %s: expected one row, got %d", code, len(rows))
+		}
+
+		if data, ok := rows[0].Data.(*view.DistributionData); !ok || data.Count != 1 {
+			t.Errorf("status code %s: expected one request, got %v", code, rows[0].Data)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package ocgorm
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/jinzhu/gorm"
+	"go.opencensus.io/trace"
+)
+
+// Association wraps a gorm association (see gorm.DB.Association) and traces its operations
+// as gorm:association:<operation> spans.
+//
+// Some association operations (eg. appending to a many to many association) execute queries bypassing the callbacks,
+// the spans make them visible. The queries executed through the callbacks are recorded as children of the spans.
+type Association struct {
+	db     *gorm.DB
+	column string
+
+	// Error is the error (if any) returned by the last operation.
+	Error error
+}
+
+// WrapAssociation returns the association of the model of a db instance bound to a context (see WithContext).
+//
+//	ocgorm.WrapAssociation(ocgorm.WithContext(ctx, db).Model(&user), "Orders").Append(&order)
+func WrapAssociation(db *gorm.DB, column string) *Association {
+	return &Association{
+		db:     db,
+		column: column,
+	}
+}
+
+// Find finds the associated records (see gorm.Association.Find).
+func (a *Association) Find(value interface{}) *Association {
+	return a.trace("find", func(association *gorm.Association) *gorm.Association {
+		return association.Find(value)
+	})
+}
+
+// Append appends records to the association (see gorm.Association.Append).
+func (a *Association) Append(values ...interface{}) *Association {
+	return a.trace("append", func(association *gorm.Association) *gorm.Association {
+		return association.Append(values...)
+	})
+}
+
+// Replace replaces the associated records (see gorm.Association.Replace).
+func (a *Association) Replace(values ...interface{}) *Association {
+	return a.trace("replace", func(association *gorm.Association) *gorm.Association {
+		return association.Replace(values...)
+	})
+}
+
+// Delete removes records from the association (see gorm.Association.Delete).
+func (a *Association) Delete(values ...interface{}) *Association {
+	return a.trace("delete", func(association *gorm.Association) *gorm.Association {
+		return association.Delete(values...)
+	})
+}
+
+// Clear removes every record from the association (see gorm.Association.Clear).
+func (a *Association) Clear() *Association {
+	return a.trace("clear", func(association *gorm.Association) *gorm.Association {
+		return association.Clear()
+	})
+}
+
+// Count returns the number of associated records (see gorm.Association.Count).
+//
+// The query is executed through the row query callbacks, so it is recorded in the query stats as well.
+func (a *Association) Count() int {
+	var count int
+
+	a.trace("count", func(association *gorm.Association) *gorm.Association {
+		count = association.Count()
+
+		return association
+	})
+
+	return count
+}
+
+// trace runs an association operation in a span.
+func (a *Association) trace(operation string, fn func(association *gorm.Association) *gorm.Association) *Association {
+	c := callbacksFromDB(a.db)
+
+	rctx, _ := a.db.Get(contextScopeKey)
+	ctx, ok := rctx.(context.Context)
+	if !ok || ctx == nil {
+		ctx = context.Background()
+	}
+
+	var span *trace.Span
+
+	if !c.disableTrace && (trace.FromContext(ctx) != nil || c.allowRoot) {
+		// Root spans are started from the bound context as well, so that its values (eg. tags) are kept
+		ctx, span = trace.StartSpan(
+			ctx,
+			"gorm:association:"+operation,
+			trace.WithSpanKind(c.spanKind),
+			trace.WithSampler(c.spanSampler()),
+		)
+
+		scope := a.db.NewScope(a.db.Value)
+
+		span.AddAttributes(c.defaultAttributes...)
+		span.AddAttributes(
+			trace.StringAttribute(OperationAttribute, "association:"+operation),
+			trace.StringAttribute(TableAttribute, scope.TableName()),
+			trace.StringAttribute(AssociationAttribute, a.column),
+			trace.StringAttribute(AssociationTableAttribute, associationTable(scope, a.column)),
+		)
+	}
+
+	association := fn(a.db.Set(contextScopeKey, ctx).Association(a.column))
+
+	a.Error = association.Error
+
+	if span != nil {
+		if a.Error != nil {
+			span.SetStatus(c.statusFromError(a.Error))
+		}
+
+		span.End()
+	}
+
+	return a
+}
+
+// associationTable returns the table of the records associated through a field of the model of a scope.
+func associationTable(scope *gorm.Scope, column string) string {
+	field, ok := scope.FieldByName(column)
+	if !ok {
+		return ""
+	}
+
+	typ := field.Struct.Type
+	for typ.Kind() == reflect.Slice || typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	if typ.Kind() != reflect.Struct {
+		return ""
+	}
+
+	return scope.New(reflect.New(typ).Interface()).TableName()
+}
@@ -0,0 +1,136 @@
+package ocgorm_test
+
+import (
+	"context"
+	"testing"
+
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"go.opencensus.io/trace"
+
+	"github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgorm"
+	"github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgorm/ocgormtest"
+)
+
+// checkAssociationSpan checks the span of an association operation of the books of an author.
+func checkAssociationSpan(t *testing.T, exporter *ocgormtest.Exporter, operation string, parent trace.SpanContext) {
+	t.Helper()
+
+	spans := exporter.SpansWithName("gorm:association:" + operation)
+	if len(spans) != 1 {
+		t.Fatalf("expected one %s span, got %d", operation, len(spans))
+	}
+
+	span := spans[0]
+
+	if span.ParentSpanID != parent.SpanID {
+		t.Errorf("the %s span is not a child of the parent span", operation)
+	}
+
+	expected := map[string]interface{}{
+		ocgorm.OperationAttribute:        "association:" + operation,
+		ocgorm.TableAttribute:            "authors",
+		ocgorm.AssociationAttribute:      "Books",
+		ocgorm.AssociationTableAttribute: "books",
+	}
+
+	for key, value := range expected {
+		if actual := span.Attributes[key]; actual != value {
+			t.Errorf("%s: expected attribute %s to be %q, got %v", operation, key, value, actual)
+		}
+	}
+}
+
+func TestWrapAssociation(t *testing.T) {
+	db := newTestDB(t)
+
+	author := Author{Name: "John", Books: []Book{{Title: "First"}}}
+
+	if err := db.Create(&author).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// Views are registered after the setup so that only the association operations are recorded
+	defer registerViews(t, ocgorm.SQLClientCallsView, ocgorm.SQLClientLatencyView)()
+
+	exporter, unregister := ocgormtest.NewExporter()
+	defer unregister()
+
+	ctx, parent := trace.StartSpan(context.Background(), "parent")
+
+	association := ocgorm.WrapAssociation(ocgorm.WithContext(ctx, db).Model(&author), "Books")
+
+	if err := association.Append(&Book{Title: "Second"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	count := association.Count()
+
+	parent.End()
+
+	if association.Error != nil {
+		t.Fatal(association.Error)
+	}
+
+	if count != 2 {
+		t.Errorf("expected two books, got %d", count)
+	}
+
+	checkAssociationSpan(t, exporter, "append", parent.SpanContext())
+	checkAssociationSpan(t, exporter, "count", parent.SpanContext())
+
+	for _, v := range []*view.View{ocgorm.SQLClientCallsView, ocgorm.SQLClientLatencyView} {
+		rows, err := ocgormtest.ViewRows(
+			v,
+			tag.Tag{Key: ocgorm.Operation, Value: ocgorm.OpCount},
+			tag.Tag{Key: ocgorm.Table, Value: "books"},
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(rows) != 1 {
+			t.Errorf("%s: expected one row for the count, got %d", v.Name, len(rows))
+		}
+	}
+}
+
+func TestWrapAssociation_RootSpan(t *testing.T) {
+	db := newTestDB(t, ocgorm.AllowRoot(true))
+
+	author := Author{Name: "John", Books: []Book{{Title: "First"}}}
+
+	if err := db.Create(&author).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	defer registerViews(t, ocgorm.SQLClientCallsByRouteView)()
+
+	exporter, unregister := ocgormtest.NewExporter()
+	defer unregister()
+
+	ctx, err := tag.New(context.Background(), tag.Upsert(ochttp.KeyServerRoute, "/authors/:id/books"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ocgorm.WrapAssociation(ocgorm.WithContext(ctx, db).Model(&author), "Books").Count()
+
+	if spans := exporter.SpansWithName("gorm:association:count"); len(spans) != 1 {
+		t.Fatalf("expected one root span, got %d", len(spans))
+	}
+
+	// The values of the bound context are kept
+	rows, err := ocgormtest.ViewRows(
+		ocgorm.SQLClientCallsByRouteView,
+		tag.Tag{Key: ochttp.KeyServerRoute, Value: "/authors/:id/books"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) != 1 {
+		t.Errorf("expected the count to be recorded with the route, got %d rows", len(rows))
+	}
+}
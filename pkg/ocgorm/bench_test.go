@@ -0,0 +1,72 @@
+package ocgorm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"go.opencensus.io/trace"
+)
+
+type benchmarkPerson struct {
+	ID        uint
+	FirstName string
+}
+
+// benchmarkCallbacks measures the cost of the instrumentation callbacks of a single operation.
+// Passing a nil configuration measures the baseline (creating the scope without instrumentation).
+func benchmarkCallbacks(b *testing.B, c *callbacks) {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer db.Close()
+
+	ctx, span := trace.StartSpan(context.Background(), "parent", trace.WithSampler(trace.AlwaysSample()))
+	defer span.End()
+
+	db = WithContext(ctx, db)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		scope := db.NewScope(&benchmarkPerson{})
+
+		if c != nil {
+			c.before(scope, OpQuery)
+			c.after(scope)
+		}
+	}
+}
+
+func BenchmarkCallbacks(b *testing.B) {
+	b.Run("Baseline", func(b *testing.B) {
+		benchmarkCallbacks(b, nil)
+	})
+
+	b.Run("Enabled", func(b *testing.B) {
+		benchmarkCallbacks(b, newCallbacks())
+	})
+
+	b.Run("CallerAttribute", func(b *testing.B) {
+		benchmarkCallbacks(b, newCallbacks(CallerAttribute(true)))
+	})
+
+	b.Run("PoolStatsAttributes", func(b *testing.B) {
+		benchmarkCallbacks(b, newCallbacks(PoolStatsAttributes(true)))
+	})
+
+	b.Run("DisableTrace", func(b *testing.B) {
+		benchmarkCallbacks(b, newCallbacks(DisableTrace(true)))
+	})
+
+	b.Run("DisableStats", func(b *testing.B) {
+		benchmarkCallbacks(b, newCallbacks(DisableStats(true)))
+	})
+
+	b.Run("Disabled", func(b *testing.B) {
+		benchmarkCallbacks(b, newCallbacks(DisableTrace(true), DisableStats(true)))
+	})
+}
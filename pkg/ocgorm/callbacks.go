@@ -3,17 +3,48 @@ package ocgorm
 import (
 	"context"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/jinzhu/gorm"
+	"go.opencensus.io/plugin/ochttp"
 	"go.opencensus.io/stats"
 	"go.opencensus.io/tag"
 	"go.opencensus.io/trace"
 )
 
 // Gorm scope keys
+//
+// The span and the start time are stored as instance settings (see gorm.Scope.InstanceSet),
+// so they are never visible to other (eg. nested) operations.
 var (
-	contextScopeKey = "_opencensusContext"
-	spanScopeKey    = "_opencensusSpan"
+	contextScopeKey   = "_opencensusContext"
+	spanScopeKey      = "_opencensusSpan"
+	startScopeKey     = "_opencensusStart"
+	operationScopeKey = "_opencensusOperation"
+	skipScopeKey      = "_opencensusSkip"
+	callbacksScopeKey = "_opencensusCallbacks"
+	preloadScopeKey   = "_opencensusPreload"
+
+	// The operation determined by queryOperation
+	queryOperationScopeKey = "_opencensusQueryOperation"
+)
+
+// Operations instrumented by the callbacks
+const (
+	OpCreate   = "create"
+	OpQuery    = "query"
+	OpRowQuery = "row_query"
+	OpUpdate   = "update"
+	OpDelete   = "delete"
+)
+
+// Operations recorded instead of OpQuery (Scan) and OpRowQuery (Count, Pluck)
+// when the gorm API executing the query can be determined
+const (
+	OpCount = "count"
+	OpPluck = "pluck"
+	OpScan  = "scan"
 )
 
 // Option allows for managing ocgorm configuration using functional options.
@@ -35,11 +66,194 @@ func (a AllowRoot) apply(c *callbacks) {
 	c.allowRoot = bool(a)
 }
 
+// DisableTrace disables tracing of queries.
+type DisableTrace bool
+
+func (d DisableTrace) apply(c *callbacks) {
+	c.disableTrace = bool(d)
+}
+
+// DisableStats disables recording stats of queries.
+type DisableStats bool
+
+func (d DisableStats) apply(c *callbacks) {
+	c.disableStats = bool(d)
+}
+
 // Query allows recording the sql queries in spans.
 type Query bool
 
 func (q Query) apply(c *callbacks) {
-	c.query = bool(q)
+	c.setQuery(bool(q))
+}
+
+// QueryOnError allows recording the sql queries in spans of failed operations only.
+// Query takes precedence over this option: when it is enabled, queries are always recorded.
+type QueryOnError bool
+
+func (q QueryOnError) apply(c *callbacks) {
+	c.queryOnError = bool(q)
+}
+
+// QueryVars allows recording the sql query bind variables in spans.
+// It only takes effect when Query (or QueryOnError) is enabled as well.
+type QueryVars bool
+
+func (q QueryVars) apply(c *callbacks) {
+	c.queryVars = bool(q)
+}
+
+// ObfuscateQuery replaces literals in recorded sql queries with placeholders.
+// Query variables are not recorded when obfuscation is enabled.
+//
+// String literals are found according to the dialect of the database:
+// backslash escapes are only recognized in MySQL (and E'...' strings), dollar-quoted strings only in PostgreSQL.
+type ObfuscateQuery bool
+
+func (o ObfuscateQuery) apply(c *callbacks) {
+	c.setObfuscateQuery(bool(o))
+}
+
+// QueryFingerprint allows recording the normalized form of sql queries (literals replaced with placeholders)
+// and a hash of it in spans, so spans can be grouped by query shape.
+// It is independent of the Query option.
+type QueryFingerprint bool
+
+func (q QueryFingerprint) apply(c *callbacks) {
+	c.queryFingerprint = bool(q)
+}
+
+// StatementTag allows recording the Statement tag with each measurement (see StatementViews).
+// The tag is the query name set in the context (see WithQueryName) or the hash of the query fingerprint.
+//
+// Fingerprints of dynamically built queries can have a high cardinality: prefer naming queries in these cases.
+type StatementTag bool
+
+func (s StatementTag) apply(c *callbacks) {
+	c.statementTag = bool(s)
+}
+
+// MaxQueryLength limits the length of recorded sql queries (and query variables).
+// Longer values are truncated. Zero means no limit.
+type MaxQueryLength int
+
+func (m MaxQueryLength) apply(c *callbacks) {
+	c.setMaxQueryLength(int(m))
+}
+
+// SemanticAttributes allows recording OpenTelemetry semantic convention attributes
+// (db.system, db.name, db.statement, net.peer.name, net.peer.port) in spans.
+type SemanticAttributes bool
+
+func (s SemanticAttributes) apply(c *callbacks) {
+	c.semanticAttributes = bool(s)
+}
+
+// DatabaseInfo sets the database name and the address of the database server
+// recorded in spans when SemanticAttributes is enabled.
+func DatabaseInfo(name string, host string, port int) Option {
+	return OptionFunc(func(c *callbacks) {
+		c.dbName = name
+		c.peerName = host
+		c.peerPort = port
+	})
+}
+
+// DatadogCompat allows recording the attributes required by Datadog APM (resource.name, span.type, service.name) in spans.
+// The resource name is the recorded query when Query is enabled, the span name otherwise.
+type DatadogCompat bool
+
+func (d DatadogCompat) apply(c *callbacks) {
+	c.datadogCompat = bool(d)
+}
+
+// ServiceName sets the service name recorded in spans when DatadogCompat is enabled.
+type ServiceName string
+
+func (s ServiceName) apply(c *callbacks) {
+	c.serviceName = string(s)
+}
+
+// AbortOnCanceledContext aborts operations whose context (see WithContext) is already canceled
+// or past its deadline before executing the query.
+// The context error is set as the error of the operation and recorded in the span and the stats.
+// Operations excluded from instrumentation (eg. by Filter) are not aborted.
+//
+// Row queries (eg. Row, Rows, Count, Pluck) are never aborted:
+// gorm executes them regardless of the error of the operation.
+type AbortOnCanceledContext bool
+
+func (a AbortOnCanceledContext) apply(c *callbacks) {
+	c.abortOnCanceledContext = bool(a)
+}
+
+// PoolStatsAttributes allows recording a snapshot of the connection pool stats
+// (sql.conns.open, sql.conns.in_use, sql.conns.wait_count) in spans when operations start.
+//
+// Reading the stats acquires the lock of the connection pool for every operation.
+// Operations executed in a transaction have no access to the pool, so no stats are recorded for them.
+type PoolStatsAttributes bool
+
+func (p PoolStatsAttributes) apply(c *callbacks) {
+	c.poolStatsAttributes = bool(p)
+}
+
+// SlowQueryThreshold sets the duration above which queries are considered slow.
+// Slow queries are annotated in spans and counted in a separate measure.
+// Zero disables slow query detection.
+type SlowQueryThreshold time.Duration
+
+func (s SlowQueryThreshold) apply(c *callbacks) {
+	c.slowQueryThreshold = time.Duration(s)
+}
+
+// LongTransactionThreshold sets the duration above which transactions (see Begin) are considered long running.
+// Long transactions are annotated in spans and counted in a separate measure.
+// Zero disables long transaction detection.
+type LongTransactionThreshold time.Duration
+
+func (l LongTransactionThreshold) apply(c *callbacks) {
+	c.longTransactionThreshold = time.Duration(l)
+}
+
+// OnLongTransaction sets a function called when a transaction exceeding LongTransactionThreshold ends
+// (eg. to log the route or the stack of the offender).
+// The context is the one bound to the transaction.
+func OnLongTransaction(fn func(ctx context.Context, duration time.Duration)) Option {
+	return OptionFunc(func(c *callbacks) {
+		c.onLongTransaction = fn
+	})
+}
+
+// SpanNameWithTable includes the table name in the default span name (eg. gorm:query people).
+// A custom FormatSpanName function takes precedence over this option.
+type SpanNameWithTable bool
+
+func (s SpanNameWithTable) apply(c *callbacks) {
+	c.spanNameWithTable = bool(s)
+}
+
+// CountRecordNotFound allows counting record not found errors in the error count measure.
+type CountRecordNotFound bool
+
+func (r CountRecordNotFound) apply(c *callbacks) {
+	c.countRecordNotFound = bool(r)
+}
+
+// RecordNotFoundAsOK sets the span status of operations failing with a record not found error to OK.
+// These operations are never counted as errors in stats either (overriding CountRecordNotFound).
+type RecordNotFoundAsOK bool
+
+func (r RecordNotFoundAsOK) apply(c *callbacks) {
+	c.recordNotFoundAsOK = bool(r)
+}
+
+// EmptyUpdateAsNotFound treats updates and deletes affecting no rows as if the record was not found.
+// The span status of these operations is set to NotFound and their stats are recorded with a NOT_FOUND status tag.
+type EmptyUpdateAsNotFound bool
+
+func (e EmptyUpdateAsNotFound) apply(c *callbacks) {
+	c.emptyUpdateAsNotFound = bool(e)
 }
 
 // StartOptions configures the initial options applied to a span.
@@ -49,69 +263,643 @@ func StartOptions(o trace.StartOptions) Option {
 	})
 }
 
+// Sampler sets the sampler used for spans.
+// It takes precedence over the sampler set by StartOptions.
+func Sampler(sampler trace.Sampler) Option {
+	return OptionFunc(func(c *callbacks) {
+		c.sampler = sampler
+	})
+}
+
+// SpanKind sets the kind of spans (trace.SpanKindUnspecified, trace.SpanKindClient or trace.SpanKindServer).
+// Default is trace.SpanKindClient.
+type SpanKind int
+
+func (s SpanKind) apply(c *callbacks) {
+	c.spanKind = int(s)
+}
+
+// ErrorClassifiers adds classifiers used for mapping errors to span status codes.
+// Classifiers are consulted in order, the first one classifying the error wins.
+func ErrorClassifiers(classifiers ...ErrorClassifier) Option {
+	return OptionFunc(func(c *callbacks) {
+		c.errorClassifiers = append(c.errorClassifiers, classifiers...)
+	})
+}
+
+// ErrorToStatus sets a function mapping errors to span status.
+// When the function returns nil, the built-in mapping is used.
+func ErrorToStatus(fn func(operation string, scope *gorm.Scope, err error) *trace.Status) Option {
+	return OptionFunc(func(c *callbacks) {
+		c.errorToStatus = fn
+	})
+}
+
+// FormatSpanName sets a function formatting span names.
+// When the function returns an empty string, the default gorm:<operation> name is used.
+//
+// Note that the SQL query is usually not built yet when the span is started.
+func FormatSpanName(fn func(operation string, scope *gorm.Scope) string) Option {
+	return OptionFunc(func(c *callbacks) {
+		c.formatSpanName = fn
+	})
+}
+
+// SpanModifier sets a function for customizing spans.
+// It is called right after the span is started and right before it is ended.
+//
+// Unless the spans are started by a Tracer (see WithTracer),
+// the underlying OpenCensus span can be retrieved from the context using trace.FromContext.
+func SpanModifier(fn func(ctx context.Context, span Span, scope *gorm.Scope)) Option {
+	return OptionFunc(func(c *callbacks) {
+		c.spanModifier = fn
+	})
+}
+
+// ContextProvider sets a function providing the context of operations without a context set by WithContext.
+// When the function returns nil, operations are instrumented without a context.
+func ContextProvider(fn func(scope *gorm.Scope) context.Context) Option {
+	return OptionFunc(func(c *callbacks) {
+		c.contextProvider = fn
+	})
+}
+
+// OnDroppedSpan sets a function called when an operation is not traced due to a missing parent span
+// (and root spans are not allowed). It helps finding code not binding the context (see WithContext).
+func OnDroppedSpan(fn func(operation string, table string)) Option {
+	return OptionFunc(func(c *callbacks) {
+		c.onDroppedSpan = fn
+	})
+}
+
+// Filter sets a function deciding whether an operation should be instrumented.
+// When the function returns false, neither traces nor stats are recorded for the operation.
+func Filter(fn func(operation string, scope *gorm.Scope) bool) Option {
+	return OptionFunc(func(c *callbacks) {
+		c.filter = fn
+	})
+}
+
+// IgnoreTablePrefixes disables instrumentation of operations on tables starting with any of the prefixes
+// (eg. migration and fixture tables). It is applied in addition to Filter.
+func IgnoreTablePrefixes(prefixes ...string) Option {
+	return OptionFunc(func(c *callbacks) {
+		c.ignoreTablePrefixes = append(c.ignoreTablePrefixes, prefixes...)
+	})
+}
+
+// WrapCallbacks registers the instrumentation at the beginning and the end of the callback chains,
+// so that the spans and the latency include the gorm hooks (eg. BeforeCreate, AfterFind),
+// the transaction of the operation and the custom callbacks registered in the middle of the chains.
+// By default only the callbacks executing the queries (and preloading) are instrumented.
+//
+// StatementSpans always enables it: disabling it has no effect when statement spans are recorded.
+type WrapCallbacks bool
+
+func (w WrapCallbacks) apply(c *callbacks) {
+	c.wrapCallbacks = bool(w)
+}
+
+// OperationFromSQL derives the operation recorded in spans and stats from the first keyword of the query
+// (SELECT, INSERT, UPDATE, DELETE or OTHER) instead of the gorm callback (eg. query, row_query).
+// The callback name is used when the query is empty.
+type OperationFromSQL bool
+
+func (o OperationFromSQL) apply(c *callbacks) {
+	c.operationFromSQL = bool(o)
+}
+
+// DetectPluck records the row queries executed by Pluck as OpPluck instead of OpRowQuery.
+//
+// Pluck can only be told apart from Rows by inspecting the stack (once per row query),
+// so it is disabled by default.
+type DetectPluck bool
+
+func (d DetectPluck) apply(c *callbacks) {
+	c.detectPluck = bool(d)
+}
+
+// Operations limits the callbacks registered by RegisterCallbacks to the given operations (eg. OpQuery).
+// By default every operation is instrumented. Passing no operations registers no callbacks at all.
+func Operations(operations ...string) Option {
+	return OptionFunc(func(c *callbacks) {
+		c.operations = make(map[string]bool, len(operations))
+
+		for _, operation := range operations {
+			c.operations[operation] = true
+		}
+	})
+}
+
+// TableTagNormalizer sets a function normalizing table names before recording them as the Table tag,
+// keeping the cardinality of the tag under control. Spans record the original table names.
+func TableTagNormalizer(fn func(table string) string) Option {
+	return OptionFunc(func(c *callbacks) {
+		c.tableTagNormalizer = fn
+	})
+}
+
+// TableTagAllowlist records only the given table names as the Table tag, any other table is recorded as "other".
+// Spans record the original table names.
+func TableTagAllowlist(tables ...string) Option {
+	allowed := make(map[string]bool, len(tables))
+
+	for _, table := range tables {
+		allowed[table] = true
+	}
+
+	return TableTagNormalizer(func(table string) string {
+		if allowed[table] {
+			return table
+		}
+
+		return "other"
+	})
+}
+
 // DefaultAttributes sets attributes to each span.
+//
+// They are not recorded in the spans started by a Tracer (see WithTracer), use SpanAttributes instead.
 type DefaultAttributes []trace.Attribute
 
 func (d DefaultAttributes) apply(c *callbacks) {
 	c.defaultAttributes = []trace.Attribute(d)
 }
 
+// SpanAttributes sets attributes to each span, including the spans started by a Tracer (see WithTracer).
+type SpanAttributes []Attribute
+
+func (s SpanAttributes) apply(c *callbacks) {
+	c.spanAttributes = []Attribute(s)
+}
+
+// DefaultTags sets tags to each measurement.
+type DefaultTags []tag.Mutator
+
+func (d DefaultTags) apply(c *callbacks) {
+	c.defaultTags = []tag.Mutator(d)
+}
+
+// Tags sets a function deriving additional tags (eg. tenant tier, shard id) from the context of each operation.
+// The tags are recorded with the query measurements. The function is not called for operations without a context.
+//
+// Only low cardinality tags should be derived. Use ViewsWithTagKeys to add the keys of the tags to the views.
+func Tags(fn func(ctx context.Context, scope *gorm.Scope) []tag.Mutator) Option {
+	return OptionFunc(func(c *callbacks) {
+		c.tags = fn
+	})
+}
+
+// DatabaseName sets the database instance name recorded with each measurement.
+// It allows separating the stats of multiple databases used by the same process.
+//
+// It can be used both as a callback and as a connection pool stats (StatsOption) option.
+type DatabaseName string
+
+func (d DatabaseName) apply(c *callbacks) {
+	c.databaseName = string(d)
+}
+
+// Role sets the role of the database (eg. primary, replica) recorded as a span attribute and with each measurement.
+//
+// Unlike DatabaseName, several database instances can share a role: dashboards typically aggregate by role.
+// Use ViewsWithTagKeys to add the DatabaseRole tag key to the views.
+type Role string
+
+func (r Role) apply(c *callbacks) {
+	c.role = string(r)
+}
+
 type callbacks struct {
+	// Open the database without the instrumented driver layer.
+	disableDriver bool
+
+	// Operations to register callbacks for.
+	// Nil means all operations.
+	operations map[string]bool
+
+	// Derive the recorded operation from the query.
+	operationFromSQL bool
+
+	// Inspect the stack of row queries to detect Pluck calls.
+	detectPluck bool
+
+	// Register the instrumentation at the beginning and the end of the callback chains.
+	wrapCallbacks bool
+
+	// Record the statements of the operations as child spans.
+	statementSpans bool
+
+	// Record model hooks as annotations or child spans.
+	traceModelHooks bool
+	modelHookSpans  bool
+
 	// Allow ocgorm to create root spans absence of existing spans or even context.
 	// Default is to not trace ocgorm calls if no existing parent span is found
 	// in context.
 	allowRoot bool
 
+	// Disable tracing and/or recording stats.
+	disableTrace bool
+	disableStats bool
+
+	// contextProvider provides the context of operations without one.
+	contextProvider func(scope *gorm.Scope) context.Context
+
+	// onDroppedSpan is called when an operation is not traced due to a missing parent span.
+	onDroppedSpan func(operation string, table string)
+
+	// filter decides whether an operation should be instrumented.
+	filter func(operation string, scope *gorm.Scope) bool
+
+	// Operations on tables with these prefixes are not instrumented.
+	ignoreTablePrefixes []string
+
 	// Allow recording of sql queries in spans.
 	// Only allow this if it is safe to have queries recorded with respect to
 	// security.
-	query bool
+	// Accessed atomically, since it can be changed at runtime (see Config).
+	query int32
+
+	// Allow recording of sql queries in spans of failed operations.
+	queryOnError bool
+
+	// Allow recording of sql query bind variables in spans (requires query to be enabled).
+	// Only allow this if it is safe to have query parameters recorded with respect to
+	// security.
+	queryVars bool
+
+	// Replace literals in recorded sql queries with placeholders.
+	// Accessed atomically, since it can be changed at runtime (see Config).
+	obfuscateQuery int32
+
+	// Allow recording the fingerprint of sql queries in spans.
+	queryFingerprint bool
+
+	// Maximum length of recorded sql queries and query variables.
+	// Zero means no limit.
+	// Accessed atomically, since it can be changed at runtime (see Config).
+	maxQueryLength int32
+
+	// Allow recording the location of the code executing the operation in spans.
+	callerAttribute bool
+
+	// Allow recording connection pool stats in spans.
+	poolStatsAttributes bool
+
+	// Allow recording OpenTelemetry semantic convention attributes in spans.
+	semanticAttributes bool
+
+	// Database name and server address recorded as semantic convention attributes.
+	dbName   string
+	peerName string
+	peerPort int
+
+	// Allow recording Datadog APM attributes in spans.
+	datadogCompat bool
+
+	// Service name recorded as a Datadog APM attribute.
+	serviceName string
+
+	// Abort operations with a canceled context.
+	abortOnCanceledContext bool
+
+	// Duration above which queries are considered slow.
+	// Zero disables slow query detection.
+	slowQueryThreshold time.Duration
+
+	// Duration above which transactions are considered long running.
+	// Zero disables long transaction detection.
+	longTransactionThreshold time.Duration
+
+	// onLongTransaction is called when a long running transaction ends.
+	onLongTransaction func(ctx context.Context, duration time.Duration)
+
+	// Count record not found errors in the error count measure.
+	// Default is to ignore them, since they are usually not operational errors.
+	countRecordNotFound bool
+
+	// Treat record not found errors as success.
+	recordNotFoundAsOK bool
+
+	// Treat updates and deletes affecting no rows as not found.
+	emptyUpdateAsNotFound bool
 
 	// startOptions are applied to the span started around each request.
 	//
-	// StartOptions.SpanKind will always be set to trace.SpanKindClient.
+	// StartOptions.SpanKind is ignored, see spanKind instead.
 	startOptions trace.StartOptions
 
+	// Kind of the spans.
+	spanKind int
+
+	// sampler overrides StartOptions.Sampler when set.
+	sampler trace.Sampler
+
 	// DefaultAttributes will be set to each span as default.
 	defaultAttributes []trace.Attribute
+
+	// spanAttributes will be set to each span as default (see SpanAttributes).
+	spanAttributes []Attribute
+
+	// DefaultTags will be set to each measurement as default.
+	defaultTags []tag.Mutator
+
+	// tableTagNormalizer normalizes table names recorded as the Table tag.
+	tableTagNormalizer func(table string) string
+
+	// tags derives additional tags from the context of operations.
+	tags func(ctx context.Context, scope *gorm.Scope) []tag.Mutator
+
+	// Record the Statement tag with each measurement.
+	statementTag bool
+
+	// Name of the gorm dialect of the database (eg. mysql, postgres).
+	// It determines how literals are found in queries.
+	dialect string
+
+	// Namespace and measures stats are recorded into.
+	namespace string
+	measures  *measures
+
+	// Connection pool stats recording interval of Instrument.
+	statsInterval time.Duration
+
+	// Name of the database instance recorded with each measurement.
+	databaseName string
+
+	// Role of the database recorded in spans and with each measurement.
+	role string
+
+	// Include the table name in the default span name.
+	spanNameWithTable bool
+
+	// formatSpanName overrides the default span name.
+	formatSpanName func(operation string, scope *gorm.Scope) string
+
+	// spanModifier customizes spans after start and before end.
+	spanModifier func(ctx context.Context, span Span, scope *gorm.Scope)
+
+	// tracer starts the spans of the operations instead of OpenCensus when set.
+	tracer Tracer
+
+	// logger attaches gorm log entries to spans.
+	logger *Logger
+
+	// errorClassifiers map errors to span status codes.
+	errorClassifiers []ErrorClassifier
+
+	// errorToStatus overrides the built-in error to span status mapping.
+	errorToStatus func(operation string, scope *gorm.Scope, err error) *trace.Status
 }
 
 // RegisterCallbacks registers the necessary callbacks in Gorm's hook system for instrumentation.
+//
+// The configuration is also stored in the db instance, so that helpers (eg. Begin) can use it.
+//
+// The instrumentation callbacks are registered as instrumentation:before_<operation> and instrumentation:after_<operation>
+// (eg. instrumentation:before_create), so custom callbacks can be ordered relative to them.
+//
+// The gorm callbacks running within the span of the operation (eg. gorm:create, or gorm:before_create with WrapCallbacks)
+// are replaced with ones ending the span when they panic, so gorm logs an info message about replacing them.
+//
+// Operations executed by other operations inherit the context of the outer operation (carrying its span),
+// so they are recorded as child spans of the outer operation span instead of its siblings.
+// This applies to preloading associations (marked with the gorm.preload attribute)
+// and to saving associations when creating or updating records
+// (the outer span only covers saving associations when WrapCallbacks is enabled).
 func RegisterCallbacks(db *gorm.DB, opts ...Option) {
+	c := newCallbacks(opts...)
+	c.dialect = db.Dialect().GetName()
+
+	db.InstantSet(callbacksScopeKey, c)
+
+	if c.logger != nil {
+		db.SetLogger(c.logger)
+	}
+
+	if c.registers(OpCreate) {
+		before, after := c.anchors("gorm:create", "gorm:begin_transaction", "gorm:commit_or_rollback_transaction")
+
+		db.Callback().Create().Before(before).Register("instrumentation:before_create", c.beforeCreate)
+		db.Callback().Create().After(after).Register("instrumentation:after_create", c.afterCreate)
+
+		c.recoverCallbacks(db.Callback().Create, OpCreate)
+
+		if c.statementSpans {
+			c.registerStatementCallbacks(db.Callback().Create, "gorm:create")
+		}
+
+		if c.traceModelHooks {
+			c.registerHookCallbacks(db.Callback().Create, OpCreate)
+		}
+	}
+
+	if c.registers(OpQuery) {
+		before, after := c.anchors("gorm:query", "gorm:query", "gorm:after_query")
+		if after == "gorm:query" {
+			// The query span covers preloading as well
+			after = "gorm:preload"
+		}
+
+		db.Callback().Query().Before(before).Register("instrumentation:before_query", c.beforeQuery)
+		db.Callback().Query().Before("gorm:preload").Register("instrumentation:before_preload", c.beforePreload)
+		db.Callback().Query().After(after).Register("instrumentation:after_query", c.afterQuery)
+
+		c.recoverCallbacks(db.Callback().Query, OpQuery)
+
+		if c.statementSpans {
+			c.registerStatementCallbacks(db.Callback().Query, "gorm:query")
+		}
+
+		if c.traceModelHooks {
+			c.registerHookCallbacks(db.Callback().Query, OpQuery)
+		}
+	}
+
+	if c.registers(OpRowQuery) {
+		db.Callback().RowQuery().Before("gorm:row_query").Register("instrumentation:before_row_query", c.beforeRowQuery)
+		db.Callback().RowQuery().After("gorm:row_query").Register("instrumentation:after_row_query", c.afterRowQuery)
+
+		c.recoverCallbacks(db.Callback().RowQuery, OpRowQuery)
+	}
+
+	if c.registers(OpUpdate) {
+		before, after := c.anchors("gorm:update", "gorm:begin_transaction", "gorm:commit_or_rollback_transaction")
+
+		db.Callback().Update().Before(before).Register("instrumentation:before_update", c.beforeUpdate)
+		db.Callback().Update().After(after).Register("instrumentation:after_update", c.afterUpdate)
+
+		c.recoverCallbacks(db.Callback().Update, OpUpdate)
+
+		if c.statementSpans {
+			c.registerStatementCallbacks(db.Callback().Update, "gorm:update")
+		}
+
+		if c.traceModelHooks {
+			c.registerHookCallbacks(db.Callback().Update, OpUpdate)
+		}
+	}
+
+	if c.registers(OpDelete) {
+		before, after := c.anchors("gorm:delete", "gorm:begin_transaction", "gorm:commit_or_rollback_transaction")
+
+		db.Callback().Delete().Before(before).Register("instrumentation:before_delete", c.beforeDelete)
+		db.Callback().Delete().After(after).Register("instrumentation:after_delete", c.afterDelete)
+
+		c.recoverCallbacks(db.Callback().Delete, OpDelete)
+
+		if c.statementSpans {
+			c.registerStatementCallbacks(db.Callback().Delete, "gorm:delete")
+		}
+
+		if c.traceModelHooks {
+			c.registerHookCallbacks(db.Callback().Delete, OpDelete)
+		}
+	}
+}
+
+// anchors returns the names of the gorm callbacks the instrumentation callbacks are registered before and after.
+func (c *callbacks) anchors(callback string, first string, last string) (string, string) {
+	if c.wrapCallbacks {
+		return first, last
+	}
+
+	return callback, callback
+}
+
+// newCallbacks returns a new configuration with the options applied.
+func newCallbacks(opts ...Option) *callbacks {
 	c := &callbacks{
 		defaultAttributes: []trace.Attribute{},
+		spanKind:          trace.SpanKindClient,
+		measures:          defaultMeasures,
 	}
 
 	for _, opt := range opts {
 		opt.apply(c)
 	}
 
-	db.Callback().Create().Before("gorm:create").Register("instrumentation:before_create", c.beforeCreate)
-	db.Callback().Create().After("gorm:create").Register("instrumentation:after_create", c.afterCreate)
-	db.Callback().Query().Before("gorm:query").Register("instrumentation:before_query", c.beforeQuery)
-	db.Callback().Query().After("gorm:query").Register("instrumentation:after_query", c.afterQuery)
-	db.Callback().Update().Before("gorm:update").Register("instrumentation:before_update", c.beforeUpdate)
-	db.Callback().Update().After("gorm:update").Register("instrumentation:after_update", c.afterUpdate)
-	db.Callback().Delete().Before("gorm:delete").Register("instrumentation:before_delete", c.beforeDelete)
-	db.Callback().Delete().After("gorm:delete").Register("instrumentation:after_delete", c.afterDelete)
+	// Regardless of the order of the options (see SpanAttributes), without modifying the DefaultAttributes slice
+	if len(c.spanAttributes) > 0 {
+		defaultAttributes := make([]trace.Attribute, 0, len(c.defaultAttributes)+len(c.spanAttributes))
+		defaultAttributes = append(defaultAttributes, c.defaultAttributes...)
+		c.defaultAttributes = append(defaultAttributes, ocAttributes(c.spanAttributes)...)
+	}
+
+	// Regardless of the order of the options (see StatementSpans)
+	if c.statementSpans {
+		c.wrapCallbacks = true
+	}
+
+	return c
+}
+
+// registers reports whether callbacks should be registered for an operation.
+func (c *callbacks) registers(operation string) bool {
+	return c.operations == nil || c.operations[operation]
+}
+
+// callbacksFromDB returns the configuration stored in the db instance by RegisterCallbacks.
+func callbacksFromDB(db *gorm.DB) *callbacks {
+	rc, _ := db.Get(callbacksScopeKey)
+	c, ok := rc.(*callbacks)
+	if !ok || c == nil {
+		return newCallbacks()
+	}
+
+	return c
 }
 
 func (c *callbacks) before(scope *gorm.Scope, operation string) {
+	if c.disableTrace && c.disableStats {
+		return
+	}
+
+	skip := c.ignored(scope) || (c.filter != nil && !c.filter(operation, scope))
+
+	// Always set the flag: settings are inherited by the scopes of nested operations
+	scope.Set(skipScopeKey, skip)
+
+	if skip {
+		return
+	}
+
 	rctx, _ := scope.Get(contextScopeKey)
 	ctx, ok := rctx.(context.Context)
-	if !ok || ctx == nil {
+	if (!ok || ctx == nil) && c.contextProvider != nil {
+		ctx = c.contextProvider(scope)
+	}
+
+	// Custom tags are only extracted from contexts bound to the operation
+	bound := ctx != nil
+
+	if ctx == nil {
 		ctx = context.Background()
 	}
 
-	ctx = c.startTrace(ctx, scope, operation)
-	ctx = c.startStats(ctx, scope, operation)
+	// gorm skips executing the query if the scope has an error (except for row queries)
+	if c.abortOnCanceledContext && operation != OpRowQuery && ctx.Err() != nil {
+		_ = scope.Err(ctx.Err())
+	}
+
+	// Operations without a parent span are not traced unless root spans are allowed
+	uninstrumented := !c.allowRoot && !c.hasParentSpan(ctx)
+
+	if !c.disableTrace {
+		ctx = c.startTrace(ctx, scope, operation)
+	}
+
+	if !c.disableStats {
+		ctx = c.startStats(ctx, scope, operation, bound)
+
+		countTransactionStatement(scope)
+		countRequestQuery(ctx)
+
+		if uninstrumented {
+			stats.Record(ctx, c.measures.uninstrumented.M(1))
+		}
+	}
 
 	scope.Set(contextScopeKey, ctx)
+	scope.Set(operationScopeKey, operation)
+	scope.InstanceSet(startScopeKey, time.Now())
+}
+
+// hasParentSpan reports whether the context contains an OpenCensus span or a span of the tracer (if any).
+func (c *callbacks) hasParentSpan(ctx context.Context) bool {
+	return trace.FromContext(ctx) != nil || (c.tracer != nil && c.tracer.HasSpan(ctx))
+}
+
+// ignored reports whether the table of the scope matches any of the ignored prefixes.
+func (c *callbacks) ignored(scope *gorm.Scope) bool {
+	if len(c.ignoreTablePrefixes) == 0 {
+		return false
+	}
+
+	table := scope.TableName()
+
+	for _, prefix := range c.ignoreTablePrefixes {
+		if strings.HasPrefix(table, prefix) {
+			return true
+		}
+	}
+
+	return false
 }
 
 func (c *callbacks) after(scope *gorm.Scope) {
-	c.endTrace(scope)
-	c.endStats(scope)
+	if skip, _ := scope.Get(skipScopeKey); skip == true {
+		return
+	}
+
+	if !c.disableTrace {
+		c.endTrace(scope)
+	}
+
+	if !c.disableStats {
+		c.endStats(scope)
+	}
 }
 
 func (c *callbacks) startTrace(ctx context.Context, scope *gorm.Scope, operation string) context.Context {
@@ -120,94 +908,522 @@ func (c *callbacks) startTrace(ctx context.Context, scope *gorm.Scope, operation
 		ctx = context.Background()
 	}
 
-	parentSpan := trace.FromContext(ctx)
-	if parentSpan == nil && !c.allowRoot {
+	if !c.allowRoot && !c.hasParentSpan(ctx) {
+		c.droppedSpan(operation, scope)
+
 		return ctx
 	}
 
-	var span *trace.Span
+	parentSpan := trace.FromContext(ctx)
 
-	if parentSpan == nil {
-		ctx, span = trace.StartSpan(
-			context.Background(),
-			fmt.Sprintf("gorm:%s", operation),
-			trace.WithSpanKind(trace.SpanKindClient),
-			trace.WithSampler(c.startOptions.Sampler),
-		)
+	var span Span
+
+	spanName := c.spanName(operation, scope)
+
+	if c.traceModelHooks {
+		scope.InstanceSet(hookParentScopeKey, parentSpan)
+	}
+
+	if c.tracer != nil {
+		ctx, span = c.tracer.StartSpan(ctx, spanName, trace.StartOptions{SpanKind: c.spanKind, Sampler: c.spanSampler()})
+
+		span.AddAttributes(c.spanAttributes...)
 	} else {
-		_, span = trace.StartSpan(ctx, fmt.Sprintf("gorm:%s", operation))
+		startOptions := []trace.StartOption{
+			trace.WithSpanKind(c.spanKind),
+			trace.WithSampler(c.spanSampler()),
+		}
+
+		var ocspan *trace.Span
+
+		ctx, ocspan = trace.StartSpan(ctx, spanName, startOptions...)
+
+		// Includes SpanAttributes
+		ocspan.AddAttributes(c.defaultAttributes...)
+
+		span = ocSpan{ocspan}
 	}
 
-	attributes := append(
-		c.defaultAttributes,
-		trace.StringAttribute(TableAttribute, scope.TableName()),
-	)
+	attributes := []Attribute{
+		StringAttribute(OperationAttribute, operation),
+		StringAttribute(TableAttribute, scope.TableName()),
+	}
+
+	if c.role != "" {
+		attributes = append(attributes, StringAttribute(DBRoleAttribute, c.role))
+	}
+
+	if preload, _ := scope.Get(preloadScopeKey); preload == true {
+		attributes = append(attributes, BoolAttribute(PreloadAttribute, true))
+	}
+
+	if route, ok := tag.FromContext(ctx).Value(ochttp.KeyServerRoute); ok && route != "" {
+		attributes = append(attributes, StringAttribute(HTTPRouteAttribute, route))
+	}
+
+	if c.callerAttribute {
+		attributes = append(attributes, callerAttributes()...)
+	}
 
-	if c.query {
-		attributes = append(attributes, trace.StringAttribute(QueryAttribute, scope.SQL))
+	if c.poolStatsAttributes {
+		attributes = append(attributes, poolStatsAttributes(scope)...)
+	}
+
+	if c.semanticAttributes {
+		attributes = append(attributes, StringAttribute(DBSystemAttribute, dbSystem(scope.Dialect().GetName())))
+
+		if c.dbName != "" {
+			attributes = append(attributes, StringAttribute(DBNameAttribute, c.dbName))
+		}
+
+		if c.peerName != "" {
+			attributes = append(attributes, StringAttribute(NetPeerNameAttribute, c.peerName))
+		}
+
+		if c.peerPort != 0 {
+			attributes = append(attributes, Int64Attribute(NetPeerPortAttribute, int64(c.peerPort)))
+		}
 	}
 
 	span.AddAttributes(attributes...)
 
-	scope.Set(spanScopeKey, span)
+	if c.spanModifier != nil {
+		c.spanModifier(ctx, span, scope)
+	}
+
+	c.bindLogger(scope, span)
+
+	scope.InstanceSet(spanScopeKey, span)
 
 	return ctx
 }
 
+// droppedSpan notifies the OnDroppedSpan function (if any) about an operation not traced due to a missing parent span.
+func (c *callbacks) droppedSpan(operation string, scope *gorm.Scope) {
+	if c.onDroppedSpan != nil {
+		c.onDroppedSpan(operation, scope.TableName())
+	}
+}
+
+// spanSampler returns the sampler used for spans.
+func (c *callbacks) spanSampler() trace.Sampler {
+	if c.sampler != nil {
+		return c.sampler
+	}
+
+	return c.startOptions.Sampler
+}
+
+// poolStatsAttributes returns the connection pool stats as span attributes.
+func poolStatsAttributes(scope *gorm.Scope) []Attribute {
+	db := scope.DB().DB()
+	if db == nil {
+		return nil
+	}
+
+	stats := db.Stats()
+
+	return []Attribute{
+		Int64Attribute(PoolOpenConnectionsAttribute, int64(stats.OpenConnections)),
+		Int64Attribute(PoolInUseConnectionsAttribute, int64(stats.InUse)),
+		Int64Attribute(PoolWaitCountAttribute, stats.WaitCount),
+	}
+}
+
+func (c *callbacks) spanName(operation string, scope *gorm.Scope) string {
+	if c.formatSpanName != nil {
+		if name := c.formatSpanName(operation, scope); name != "" {
+			return name
+		}
+	}
+
+	if c.spanNameWithTable {
+		if table := scope.TableName(); table != "" {
+			return fmt.Sprintf("gorm:%s %s", operation, table)
+		}
+	}
+
+	return fmt.Sprintf("gorm:%s", operation)
+}
+
 func (c *callbacks) endTrace(scope *gorm.Scope) {
-	rspan, ok := scope.Get(spanScopeKey)
+	rspan, ok := scope.InstanceGet(spanScopeKey)
 	if !ok {
 		return
 	}
 
-	span, ok := rspan.(*trace.Span)
+	span, ok := rspan.(Span)
 	if !ok {
 		return
 	}
 
+	// The query is only built by gorm after the before callbacks
+	if c.recordsQuery(scope) {
+		query := c.recordedQuery(scope)
+
+		attributes := []Attribute{
+			StringAttribute(QueryAttribute, query),
+		}
+
+		if c.semanticAttributes {
+			attributes = append(attributes, StringAttribute(DBStatementAttribute, query))
+		}
+
+		if c.queryVars && !c.obfuscatesQuery() {
+			vars := formatQueryVars(scope.SQLVars)
+
+			attributes = append(attributes, StringAttribute(QueryVarsAttribute, truncateQuery(vars, c.queryLengthLimit())))
+		}
+
+		span.AddAttributes(attributes...)
+	}
+
+	span.AddAttributes(StringAttribute(TablesAttribute, strings.Join(scopeTables(scope), ",")))
+
+	if verb := c.queryOperation(scope); verb != "" {
+		span.AddAttributes(StringAttribute(OperationAttribute, verb))
+	}
+
+	if operation, _ := scope.Get(operationScopeKey); operation == OpCreate && !scope.HasError() {
+		span.AddAttributes(batchAttributes(scope.SQL, scope.DB().RowsAffected)...)
+	}
+
+	if c.queryFingerprint && scope.SQL != "" {
+		fingerprint, hash := queryFingerprint(scope.SQL, c.dialect)
+
+		span.AddAttributes(
+			StringAttribute(QueryFingerprintAttribute, truncateQuery(fingerprint, c.queryLengthLimit())),
+			StringAttribute(QueryHashAttribute, hash),
+		)
+	}
+
+	if c.datadogCompat {
+		span.AddAttributes(c.datadogAttributes(scope)...)
+	}
+
+	if duration, ok := c.slowQuery(scope); ok {
+		attributes := []Attribute{
+			StringAttribute("duration", duration.String()),
+		}
+
+		if c.recordsQuery(scope) {
+			attributes = append(attributes, StringAttribute("query", c.recordedQuery(scope)))
+		}
+
+		span.Annotate(attributes, "Slow query")
+	}
+
 	var status trace.Status
 
 	if scope.HasError() {
-		err := scope.DB().Error
-		if gorm.IsRecordNotFoundError(err) {
-			status.Code = trace.StatusCodeNotFound
-		} else {
-			status.Code = trace.StatusCodeUnknown
-		}
+		status = c.errorStatus(scope)
 
-		status.Message = err.Error()
+		errorAnnotations(span, scope.DB().Error)
+	} else if c.emptyUpdate(scope) {
+		status = trace.Status{Code: trace.StatusCodeNotFound, Message: "no rows affected"}
 	}
 
 	span.SetStatus(status)
 
+	if c.spanModifier != nil {
+		rctx, _ := scope.Get(contextScopeKey)
+		ctx, ok := rctx.(context.Context)
+		if !ok || ctx == nil {
+			ctx = context.Background()
+		}
+
+		c.spanModifier(ctx, span, scope)
+	}
+
 	span.End()
+
+	// Callbacks running after the instrumentation (eg. model hooks) must not use the ended span
+	scope.InstanceSet(spanScopeKey, nil)
+}
+
+// emptyUpdate reports whether the scope is an update or delete affecting no rows
+// that should be treated as not found.
+func (c *callbacks) emptyUpdate(scope *gorm.Scope) bool {
+	if !c.emptyUpdateAsNotFound || scope.HasError() || scope.DB().RowsAffected != 0 {
+		return false
+	}
+
+	operation, _ := scope.Get(operationScopeKey)
+
+	return operation == OpUpdate || operation == OpDelete
+}
+
+// datadogAttributes returns the attributes required by Datadog APM.
+func (c *callbacks) datadogAttributes(scope *gorm.Scope) []Attribute {
+	var resource string
+
+	if c.recordsQuery(scope) {
+		resource = c.recordedQuery(scope)
+	} else {
+		operation, _ := scope.Get(operationScopeKey)
+		resource = c.spanName(fmt.Sprint(operation), scope)
+	}
+
+	attributes := []Attribute{
+		StringAttribute(ResourceNameAttribute, resource),
+		StringAttribute(SpanTypeAttribute, "sql"),
+	}
+
+	if c.serviceName != "" {
+		attributes = append(attributes, StringAttribute(ServiceNameAttribute, c.serviceName))
+	}
+
+	return attributes
+}
+
+// batchAttributes returns the number of rows inserted (and value tuples in the statement) by INSERT statements inserting multiple rows.
+//
+// The number of rows is omitted for MySQL upserts (ON DUPLICATE KEY UPDATE):
+// MySQL reports two affected rows for each updated row.
+func batchAttributes(query string, rows int64) []Attribute {
+	tuples := queryValueTuples(query)
+
+	if rows <= 1 && tuples <= 1 {
+		return nil
+	}
+
+	var attributes []Attribute
+
+	if !onDuplicateKeyRegexp.MatchString(query) {
+		attributes = append(attributes, Int64Attribute(BatchRowsAttribute, rows))
+	}
+
+	if tuples > 0 {
+		attributes = append(attributes, Int64Attribute(BatchTuplesAttribute, int64(tuples)))
+	}
+
+	return attributes
+}
+
+// batchSize returns the number of rows sent by an INSERT statement:
+// the number of value tuples in the statement if it can be detected, the number of affected rows otherwise.
+func batchSize(query string, rows int64) int64 {
+	if tuples := queryValueTuples(query); tuples > 0 {
+		return int64(tuples)
+	}
+
+	return rows
+}
+
+// queryOperation returns the operation derived from the query when OperationFromSQL is enabled,
+// or the gorm API executing the query (see apiOperation).
+// It returns an empty string if the operation recorded by the before callback should be kept.
+//
+// The operation is determined once per scope: both the span and the stats record it.
+func (c *callbacks) queryOperation(scope *gorm.Scope) string {
+	if c.operationFromSQL {
+		return queryVerb(scope.SQL)
+	}
+
+	if operation, ok := scope.InstanceGet(queryOperationScopeKey); ok {
+		return operation.(string)
+	}
+
+	operation, _ := scope.Get(operationScopeKey)
+	apiOperation := apiOperation(scope, operation, c.detectPluck)
+
+	scope.InstanceSet(queryOperationScopeKey, apiOperation)
+
+	return apiOperation
+}
+
+// recordsQuery reports whether the query of the scope should be recorded.
+func (c *callbacks) recordsQuery(scope *gorm.Scope) bool {
+	return c.recordsQueries() || (c.queryOnError && scope.HasError())
+}
+
+// recordedQuery returns the query of the scope processed according to the configuration.
+func (c *callbacks) recordedQuery(scope *gorm.Scope) string {
+	return c.processQuery(scope.SQL)
+}
+
+// processQuery obfuscates and truncates a query according to the configuration.
+func (c *callbacks) processQuery(query string) string {
+	if c.obfuscatesQuery() {
+		query = obfuscateQuery(query, c.dialect)
+	}
+
+	return truncateQuery(query, c.queryLengthLimit())
+}
+
+// scopeTables returns every table referenced in the query of the scope.
+// It falls back to the table of the scope if no tables can be found in the query.
+func scopeTables(scope *gorm.Scope) []string {
+	if tables := queryTables(scope.SQL); len(tables) > 0 {
+		return tables
+	}
+
+	return []string{scope.TableName()}
+}
+
+// queryDuration returns the time elapsed since the start of the operation.
+func queryDuration(scope *gorm.Scope) (time.Duration, bool) {
+	rstart, _ := scope.InstanceGet(startScopeKey)
+	start, ok := rstart.(time.Time)
+	if !ok {
+		return 0, false
+	}
+
+	return time.Since(start), true
+}
+
+// slowQuery returns the duration of the operation if it exceeds the slow query threshold.
+func (c *callbacks) slowQuery(scope *gorm.Scope) (time.Duration, bool) {
+	if c.slowQueryThreshold <= 0 {
+		return 0, false
+	}
+
+	duration, ok := queryDuration(scope)
+	if !ok || duration <= c.slowQueryThreshold {
+		return 0, false
+	}
+
+	return duration, true
 }
 
-func (c *callbacks) startStats(ctx context.Context, scope *gorm.Scope, operation string) context.Context {
-	ctx, _ = tag.New(ctx, tag.Upsert(Operation, operation), tag.Upsert(Table, scope.TableName()))
+func (c *callbacks) startStats(ctx context.Context, scope *gorm.Scope, operation string, bound bool) context.Context {
+	ctx = c.tagContext(ctx, operation, scope.TableName())
+
+	if c.tags != nil && bound {
+		ctx, _ = tag.New(ctx, c.tags(ctx, scope)...)
+	}
 
 	return ctx
 }
 
-func (c *callbacks) endStats(scope *gorm.Scope) {
-	if scope.HasError() {
-		return
+// tagContext returns a context tagged for recording the stats of an operation.
+func (c *callbacks) tagContext(ctx context.Context, operation string, table string) context.Context {
+	if c.tableTagNormalizer != nil {
+		table = c.tableTagNormalizer(table)
 	}
 
+	mutators := append(
+		c.defaultTags[:len(c.defaultTags):len(c.defaultTags)],
+		tag.Upsert(Operation, operation),
+		tag.Upsert(Table, table),
+	)
+
+	if c.databaseName != "" {
+		mutators = append(mutators, tag.Upsert(Database, c.databaseName))
+	}
+
+	if c.role != "" {
+		mutators = append(mutators, tag.Upsert(DatabaseRole, c.role))
+	}
+
+	ctx, _ = tag.New(ctx, mutators...)
+
+	return ctx
+}
+
+func (c *callbacks) endStats(scope *gorm.Scope) {
 	rctx, _ := scope.Get(contextScopeKey)
 	ctx, ok := rctx.(context.Context)
 	if !ok || ctx == nil {
 		return
 	}
 
-	stats.Record(ctx, QueryCount.M(1))
+	notFound := gorm.IsRecordNotFoundError(scope.DB().Error)
+	failed := scope.HasError() && (!notFound || (c.countRecordNotFound && !c.recordNotFoundAsOK))
+
+	status := "OK"
+	if failed {
+		status = "ERROR"
+
+		if code, ok := contextStatusCode(scope, scope.DB().Error); ok {
+			status = contextStatusTag(code)
+		}
+	} else if c.emptyUpdate(scope) {
+		status = "NOT_FOUND"
+	}
+
+	mutators := []tag.Mutator{tag.Upsert(Status, status)}
+
+	if verb := c.queryOperation(scope); verb != "" {
+		mutators = append(mutators, tag.Upsert(Operation, verb))
+	}
+
+	if c.statementTag {
+		if statement := statementName(ctx, scope.SQL, c.dialect); statement != "" {
+			mutators = append(mutators, tag.Upsert(Statement, statement))
+		}
+	}
+
+	ctx, _ = tag.New(ctx, mutators...)
+
+	measurements := []stats.Measurement{c.measures.queryCount.M(1)}
+
+	if duration, ok := queryDuration(scope); ok {
+		latency := float64(duration) / float64(time.Millisecond)
+
+		measurements = append(measurements, c.measures.latencyMs.M(latency))
+	}
+
+	if _, ok := c.slowQuery(scope); ok {
+		measurements = append(measurements, c.measures.slowQueryCount.M(1))
+	}
+
+	if notFound {
+		measurements = append(measurements, c.measures.notFoundCount.M(1))
+	}
+
+	if failed {
+		measurements = append(measurements, c.measures.errorCount.M(1))
+	} else if !scope.HasError() {
+		measurements = append(measurements, c.measures.rowsAffected.M(scope.DB().RowsAffected))
+
+		// gorm sets RowsAffected to the number of scanned rows for queries,
+		// but row queries are scanned by the caller (see TraceRows)
+		if operation, _ := scope.Get(operationScopeKey); operation == OpQuery {
+			measurements = append(measurements, c.measures.rowsReturned.M(scope.DB().RowsAffected))
+		} else if operation == OpCreate {
+			measurements = append(measurements, c.measures.batchSize.M(batchSize(scope.SQL, scope.DB().RowsAffected)))
+		}
+	}
+
+	stats.Record(ctx, measurements...)
 }
 
-func (c *callbacks) beforeCreate(scope *gorm.Scope) { c.before(scope, "create") }
-func (c *callbacks) afterCreate(scope *gorm.Scope)  { c.after(scope) }
-func (c *callbacks) beforeQuery(scope *gorm.Scope)  { c.before(scope, "query") }
-func (c *callbacks) afterQuery(scope *gorm.Scope)   { c.after(scope) }
-func (c *callbacks) beforeUpdate(scope *gorm.Scope) { c.before(scope, "update") }
-func (c *callbacks) afterUpdate(scope *gorm.Scope)  { c.after(scope) }
-func (c *callbacks) beforeDelete(scope *gorm.Scope) { c.before(scope, "delete") }
-func (c *callbacks) afterDelete(scope *gorm.Scope)  { c.after(scope) }
+// statementName returns the query name set in the context or the hash of the query fingerprint.
+func statementName(ctx context.Context, query string, dialect string) string {
+	if name := queryName(ctx); name != "" {
+		return name
+	}
+
+	if query == "" {
+		return ""
+	}
+
+	_, hash := queryFingerprint(query, dialect)
+
+	return hash
+}
+
+// contextStatusTag returns the Status tag value of operations interrupted by their context.
+func contextStatusTag(code int32) string {
+	if code == trace.StatusCodeDeadlineExceeded {
+		return "DEADLINE_EXCEEDED"
+	}
+
+	return "CANCELLED"
+}
+
+func (c *callbacks) beforeCreate(scope *gorm.Scope)   { c.before(scope, OpCreate) }
+func (c *callbacks) afterCreate(scope *gorm.Scope)    { c.after(scope) }
+func (c *callbacks) beforeQuery(scope *gorm.Scope)    { c.before(scope, OpQuery) }
+func (c *callbacks) afterQuery(scope *gorm.Scope)     { c.after(scope) }
+func (c *callbacks) beforeRowQuery(scope *gorm.Scope) { c.before(scope, OpRowQuery) }
+func (c *callbacks) afterRowQuery(scope *gorm.Scope)  { c.after(scope) }
+func (c *callbacks) beforeUpdate(scope *gorm.Scope)   { c.before(scope, OpUpdate) }
+func (c *callbacks) afterUpdate(scope *gorm.Scope)    { c.after(scope) }
+func (c *callbacks) beforeDelete(scope *gorm.Scope)   { c.before(scope, OpDelete) }
+func (c *callbacks) afterDelete(scope *gorm.Scope)    { c.after(scope) }
+
+// beforePreload marks the queries issued for preloading associations.
+// The query span is still open at this point, so these queries become its children.
+func (c *callbacks) beforePreload(scope *gorm.Scope) { scope.Set(preloadScopeKey, true) }
@@ -0,0 +1,297 @@
+package ocgorm_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"go.opencensus.io/trace"
+
+	"github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgorm"
+	"github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgorm/ocgormtest"
+)
+
+func TestCallbacks(t *testing.T) {
+	tests := []struct {
+		name      string
+		operation string
+		span      string
+		run       func(db *gorm.DB) error
+	}{
+		{
+			name:      "create",
+			operation: ocgorm.OpCreate,
+			span:      "gorm:create",
+			run: func(db *gorm.DB) error {
+				return db.Create(&Person{FirstName: "Jane", LastName: "Doe"}).Error
+			},
+		},
+		{
+			name:      "query",
+			operation: ocgorm.OpQuery,
+			span:      "gorm:query",
+			run: func(db *gorm.DB) error {
+				var people []Person
+
+				return db.Find(&people).Error
+			},
+		},
+		{
+			name:      "update",
+			operation: ocgorm.OpUpdate,
+			span:      "gorm:update",
+			run: func(db *gorm.DB) error {
+				return db.Model(&Person{}).Where("first_name = ?", "John").Update("last_name", "Smith").Error
+			},
+		},
+		{
+			name:      "delete",
+			operation: ocgorm.OpDelete,
+			span:      "gorm:delete",
+			run: func(db *gorm.DB) error {
+				return db.Where("first_name = ?", "John").Delete(&Person{}).Error
+			},
+		},
+		{
+			name:      "row_query",
+			operation: ocgorm.OpCount,
+			span:      "gorm:row_query",
+			run: func(db *gorm.DB) error {
+				var count int
+
+				return db.Model(&Person{}).Count(&count).Error
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			db := newTestDB(t)
+
+			if err := db.Create(&Person{FirstName: "John", LastName: "Doe"}).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			// Views are registered after the setup so that only the tested operation is recorded
+			defer registerViews(t, ocgorm.SQLClientCallsView)()
+
+			exporter, unregister := ocgormtest.NewExporter()
+			defer unregister()
+
+			ctx, parent := trace.StartSpan(context.Background(), "parent")
+
+			if err := test.run(ocgorm.WithContext(ctx, db)); err != nil {
+				t.Fatal(err)
+			}
+
+			parent.End()
+
+			span := findSpan(t, exporter, test.span, "people")
+
+			if span.ParentSpanID != parent.SpanContext().SpanID {
+				t.Error("the operation span is not a child of the parent span")
+			}
+
+			if actual := span.Attributes[ocgorm.OperationAttribute]; actual != test.operation {
+				t.Errorf("expected operation %q, got %v", test.operation, actual)
+			}
+
+			if span.Status.Code != trace.StatusCodeOK {
+				t.Errorf("expected status OK, got %d: %s", span.Status.Code, span.Status.Message)
+			}
+
+			rows, err := ocgormtest.ViewRows(
+				ocgorm.SQLClientCallsView,
+				tag.Tag{Key: ocgorm.Operation, Value: test.operation},
+				tag.Tag{Key: ocgorm.Table, Value: "people"},
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(rows) != 1 {
+				t.Fatalf("expected one row, got %d", len(rows))
+			}
+
+			if data, ok := rows[0].Data.(*view.CountData); !ok || data.Value != 1 {
+				t.Errorf("expected one call, got %v", rows[0].Data)
+			}
+		})
+	}
+}
+
+func TestAbortOnCanceledContext(t *testing.T) {
+	db := newTestDB(t, ocgorm.AbortOnCanceledContext(true))
+
+	if err := db.Create(&Person{FirstName: "John", LastName: "Doe"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	exporter, unregister := ocgormtest.NewExporter()
+	defer unregister()
+
+	ctx, parent := trace.StartSpan(context.Background(), "parent")
+	defer parent.End()
+
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+
+	var people []Person
+
+	err := ocgorm.WithContext(ctx, db).Find(&people).Error
+	if err != context.Canceled {
+		t.Fatalf("expected the context error, got %v", err)
+	}
+
+	if len(people) != 0 {
+		t.Errorf("expected the query not to be executed, got %d records", len(people))
+	}
+
+	span := findSpan(t, exporter, "gorm:query", "people")
+
+	if span.Status.Code != trace.StatusCodeCancelled {
+		t.Errorf("expected status code %d, got %d", trace.StatusCodeCancelled, span.Status.Code)
+	}
+
+	// Row queries are executed anyway, so they are not aborted
+	var count int
+
+	if err := ocgorm.WithContext(ctx, db).Model(&Person{}).Count(&count).Error; err != nil {
+		t.Fatalf("expected the row query not to be aborted, got %v", err)
+	}
+
+	if count != 1 {
+		t.Errorf("expected one record, got %d", count)
+	}
+
+	span = findSpan(t, exporter, "gorm:row_query", "people")
+
+	if span.Status.Code != trace.StatusCodeOK {
+		t.Errorf("expected status OK, got %d: %s", span.Status.Code, span.Status.Message)
+	}
+}
+
+func TestAPIOperation(t *testing.T) {
+	type name struct {
+		FirstName string
+	}
+
+	tests := []struct {
+		name      string
+		opts      []ocgorm.Option
+		operation string
+		run       func(db *gorm.DB) error
+	}{
+		{
+			name:      "find",
+			operation: ocgorm.OpQuery,
+			run: func(db *gorm.DB) error {
+				var people []Person
+
+				return db.Find(&people).Error
+			},
+		},
+		{
+			name:      "scan",
+			operation: ocgorm.OpScan,
+			run: func(db *gorm.DB) error {
+				var names []name
+
+				return db.Table("people").Select("first_name").Scan(&names).Error
+			},
+		},
+		{
+			name:      "count",
+			operation: ocgorm.OpCount,
+			run: func(db *gorm.DB) error {
+				var count int
+
+				return db.Model(&Person{}).Count(&count).Error
+			},
+		},
+		{
+			name:      "pluck",
+			opts:      []ocgorm.Option{ocgorm.DetectPluck(true)},
+			operation: ocgorm.OpPluck,
+			run: func(db *gorm.DB) error {
+				var names []string
+
+				return db.Model(&Person{}).Pluck("first_name", &names).Error
+			},
+		},
+		{
+			name:      "pluck without detection",
+			operation: ocgorm.OpRowQuery,
+			run: func(db *gorm.DB) error {
+				var names []string
+
+				return db.Model(&Person{}).Pluck("first_name", &names).Error
+			},
+		},
+		{
+			name:      "row",
+			operation: ocgorm.OpRowQuery,
+			run: func(db *gorm.DB) error {
+				var firstName string
+
+				return db.Table("people").Select("first_name").Row().Scan(&firstName)
+			},
+		},
+		{
+			name:      "rows",
+			opts:      []ocgorm.Option{ocgorm.DetectPluck(true)},
+			operation: ocgorm.OpRowQuery,
+			run: func(db *gorm.DB) error {
+				rows, err := db.Table("people").Select("first_name").Rows()
+				if err != nil {
+					return err
+				}
+
+				return rows.Close()
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			db := newTestDB(t, test.opts...)
+
+			if err := db.Create(&Person{FirstName: "John", LastName: "Doe"}).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			defer registerViews(t, ocgorm.SQLClientCallsView)()
+
+			exporter, unregister := ocgormtest.NewExporter()
+			defer unregister()
+
+			ctx, parent := trace.StartSpan(context.Background(), "parent")
+
+			if err := test.run(ocgorm.WithContext(ctx, db)); err != nil {
+				t.Fatal(err)
+			}
+
+			parent.End()
+
+			spans := exporter.SpansWithAttribute(ocgorm.OperationAttribute, test.operation)
+			if len(spans) != 1 {
+				t.Errorf("expected one span with operation %q, got %d", test.operation, len(spans))
+			}
+
+			rows, err := ocgormtest.ViewRows(ocgorm.SQLClientCallsView, tag.Tag{Key: ocgorm.Operation, Value: test.operation})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(rows) != 1 {
+				t.Errorf("expected one row with operation %q, got %d", test.operation, len(rows))
+			}
+		})
+	}
+}
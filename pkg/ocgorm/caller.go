@@ -0,0 +1,65 @@
+package ocgorm
+
+import (
+	"runtime"
+	"strings"
+)
+
+// Code location attributes recorded on the span when CallerAttribute is enabled.
+const (
+	CodeFunctionAttribute = "code.function"
+	CodeFilepathAttribute = "code.filepath"
+	CodeLinenoAttribute   = "code.lineno"
+)
+
+// CallerAttribute allows recording the location of the code executing the operation
+// (code.function, code.filepath, code.lineno) in spans.
+//
+// The location is found by walking the stack, which has a non-negligible cost for every operation.
+type CallerAttribute bool
+
+func (a CallerAttribute) apply(c *callbacks) {
+	c.callerAttribute = bool(a)
+}
+
+// callerSkipPrefixes contains the prefixes of the functions skipped when looking for the caller.
+var callerSkipPrefixes = []string{
+	"github.com/jinzhu/gorm.",
+	"github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgorm.",
+	"runtime.",
+	"reflect.",
+}
+
+// callerAttributes returns the location of the first function calling gorm (or ocgorm) as span attributes.
+func callerAttributes() []Attribute {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(3, pcs)
+
+	frames := runtime.CallersFrames(pcs[:n])
+
+	for {
+		frame, more := frames.Next()
+
+		if !skipCaller(frame.Function) {
+			return []Attribute{
+				StringAttribute(CodeFunctionAttribute, frame.Function),
+				StringAttribute(CodeFilepathAttribute, frame.File),
+				Int64Attribute(CodeLinenoAttribute, int64(frame.Line)),
+			}
+		}
+
+		if !more {
+			return nil
+		}
+	}
+}
+
+func skipCaller(function string) bool {
+	for _, prefix := range callerSkipPrefixes {
+		if strings.HasPrefix(function, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
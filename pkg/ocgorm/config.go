@@ -0,0 +1,73 @@
+package ocgorm
+
+import (
+	"sync/atomic"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Config is a handle to the settings of the callbacks that can be changed at runtime
+// (eg. to record queries temporarily during an incident).
+//
+// It is safe for concurrent use: the callbacks read the settings atomically on each call.
+type Config struct {
+	c *callbacks
+}
+
+// RegisterCallbacksWithConfig registers the callbacks (see RegisterCallbacks)
+// and returns a handle to the settings that can be changed at runtime.
+func RegisterCallbacksWithConfig(db *gorm.DB, opts ...Option) *Config {
+	RegisterCallbacks(db, opts...)
+
+	return &Config{c: callbacksFromDB(db)}
+}
+
+// SetQueryRecording enables or disables recording the sql queries in spans (see Query).
+func (cfg *Config) SetQueryRecording(enabled bool) {
+	cfg.c.setQuery(enabled)
+}
+
+// SetQueryObfuscation enables or disables replacing literals in recorded sql queries with placeholders (see ObfuscateQuery).
+func (cfg *Config) SetQueryObfuscation(enabled bool) {
+	cfg.c.setObfuscateQuery(enabled)
+}
+
+// SetMaxQueryLength limits the length of recorded sql queries (see MaxQueryLength).
+func (cfg *Config) SetMaxQueryLength(length int) {
+	cfg.c.setMaxQueryLength(length)
+}
+
+func (c *callbacks) setQuery(enabled bool) {
+	atomic.StoreInt32(&c.query, boolToInt32(enabled))
+}
+
+// recordsQueries reports whether sql queries are recorded in spans.
+func (c *callbacks) recordsQueries() bool {
+	return atomic.LoadInt32(&c.query) != 0
+}
+
+func (c *callbacks) setObfuscateQuery(enabled bool) {
+	atomic.StoreInt32(&c.obfuscateQuery, boolToInt32(enabled))
+}
+
+// obfuscatesQuery reports whether literals in recorded sql queries are replaced with placeholders.
+func (c *callbacks) obfuscatesQuery() bool {
+	return atomic.LoadInt32(&c.obfuscateQuery) != 0
+}
+
+func (c *callbacks) setMaxQueryLength(length int) {
+	atomic.StoreInt32(&c.maxQueryLength, int32(length))
+}
+
+// queryLengthLimit returns the maximum length of recorded sql queries.
+func (c *callbacks) queryLengthLimit() int {
+	return int(atomic.LoadInt32(&c.maxQueryLength))
+}
+
+func boolToInt32(b bool) int32 {
+	if b {
+		return 1
+	}
+
+	return 0
+}
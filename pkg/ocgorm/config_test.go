@@ -0,0 +1,103 @@
+package ocgorm_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	"go.opencensus.io/trace"
+
+	"github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgorm"
+	"github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgorm/ocgormtest"
+)
+
+func TestConfig(t *testing.T) {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// Every connection has its own in-memory database
+	db.DB().SetMaxOpenConns(1)
+
+	if err := db.AutoMigrate(&Person{}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := ocgorm.RegisterCallbacksWithConfig(db)
+
+	exporter, unregister := ocgormtest.NewExporter()
+	defer unregister()
+
+	ctx, span := trace.StartSpan(context.Background(), "parent")
+	defer span.End()
+
+	db = ocgorm.WithContext(ctx, db)
+
+	// The settings are changed while queries are running (see go test -race)
+	var wg sync.WaitGroup
+
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+
+		go func(i int) {
+			defer wg.Done()
+
+			cfg.SetQueryRecording(i%2 == 0)
+			cfg.SetQueryObfuscation(i%2 == 1)
+			cfg.SetMaxQueryLength(i * 10)
+		}(i)
+
+		go func() {
+			defer wg.Done()
+
+			var people []Person
+
+			if err := db.Find(&people).Error; err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	cfg.SetQueryRecording(true)
+	cfg.SetQueryObfuscation(false)
+	cfg.SetMaxQueryLength(0)
+
+	exporter.Reset()
+
+	var people []Person
+
+	if err := db.Where("first_name = ?", "John").Find(&people).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	spans := exporter.SpansWithName("gorm:query")
+	if len(spans) != 1 {
+		t.Fatalf("expected one query span, got %d", len(spans))
+	}
+
+	if _, ok := spans[0].Attributes[ocgorm.QueryAttribute]; !ok {
+		t.Error("expected the query to be recorded once enabled")
+	}
+
+	cfg.SetQueryRecording(false)
+
+	exporter.Reset()
+
+	if err := db.Find(&people).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	spans = exporter.SpansWithName("gorm:query")
+	if len(spans) != 1 {
+		t.Fatalf("expected one query span, got %d", len(spans))
+	}
+
+	if _, ok := spans[0].Attributes[ocgorm.QueryAttribute]; ok {
+		t.Error("expected the query not to be recorded once disabled")
+	}
+}
@@ -7,6 +7,29 @@ import (
 )
 
 // WithContext sets the current context in the db instance for instrumentation.
+//
+// The returned instance is a clone of db: search conditions and settings of db are preserved, db itself is not modified.
+//
+// Note that earlier versions returned a new instance (see gorm.DB.New), discarding the search conditions and settings.
+// Pass db.New() explicitly when the conditions of a reused instance should not apply.
 func WithContext(ctx context.Context, db *gorm.DB) *gorm.DB {
-	return db.New().Set(contextScopeKey, ctx)
+	return db.Set(contextScopeKey, ctx)
+}
+
+// queryNameContextKey is the context key of the query name.
+type queryNameContextKey struct{}
+
+// WithQueryName sets a name for the queries executed with the context (see the StatementTag option).
+//
+// Query names are recorded as the Statement tag instead of the query fingerprint,
+// keeping the cardinality of the tag under control.
+func WithQueryName(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, queryNameContextKey{}, name)
+}
+
+// queryName returns the query name set in the context (if any).
+func queryName(ctx context.Context) string {
+	name, _ := ctx.Value(queryNameContextKey{}).(string)
+
+	return name
 }
@@ -0,0 +1,65 @@
+package ocgorm_test
+
+import (
+	"context"
+	"testing"
+
+	"go.opencensus.io/trace"
+
+	"github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgorm"
+	"github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgorm/ocgormtest"
+)
+
+func TestWithContext_PrebuiltChain(t *testing.T) {
+	db := newTestDB(t)
+
+	for _, name := range []string{"John", "Jane"} {
+		if err := db.Create(&Person{FirstName: name}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	exporter, unregister := ocgormtest.NewExporter()
+	defer unregister()
+
+	ctx, span := trace.StartSpan(context.Background(), "parent")
+
+	chain := db.Where("first_name = ?", "Jane")
+
+	var people []Person
+
+	if err := ocgorm.WithContext(ctx, chain).Find(&people).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	span.End()
+
+	if len(people) != 1 || people[0].FirstName != "Jane" {
+		t.Errorf("expected the search conditions of the chain to be preserved, got %+v", people)
+	}
+
+	if spans := exporter.SpansWithName("gorm:query"); len(spans) != 1 || spans[0].ParentSpanID != span.SpanContext().SpanID {
+		t.Error("expected a query span parented by the bound context")
+	}
+
+	exporter.Reset()
+
+	// The chain itself is not bound to the context
+	if err := chain.Find(&people).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if spans := exporter.SpansWithName("gorm:query"); len(spans) != 0 {
+		t.Errorf("expected no spans for the original chain, got %d", len(spans))
+	}
+}
+
+func TestWithContext_Settings(t *testing.T) {
+	db := newTestDB(t).Set("custom:setting", 42)
+
+	bound := ocgorm.WithContext(context.Background(), db)
+
+	if value, ok := bound.Get("custom:setting"); !ok || value != 42 {
+		t.Errorf("expected the settings of db to be preserved, got %v", value)
+	}
+}
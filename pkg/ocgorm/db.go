@@ -0,0 +1,191 @@
+package ocgorm
+
+import (
+	"context"
+
+	"github.com/jinzhu/gorm"
+	"go.opencensus.io/trace"
+)
+
+// DB wraps a *gorm.DB and requires a context for every operation that executes a query.
+//
+// Accepting a DB instead of a *gorm.DB makes forgetting WithContext impossible:
+// the compiler enforces passing a context to each operation.
+//
+// Operations return a new DB holding the result of the operation (eg. db.Create(ctx, &person).Error).
+// Their *Context variants (eg. CreateContext) return the error returned by gorm directly.
+type DB struct {
+	db *gorm.DB
+
+	// Error is the error (if any) returned by the last operation.
+	Error error
+
+	// RowsAffected is the number of rows affected by the last operation.
+	RowsAffected int64
+}
+
+// Wrap returns a new DB wrapping a *gorm.DB instance.
+func Wrap(db *gorm.DB) *DB {
+	return &DB{
+		db:           db,
+		Error:        db.Error,
+		RowsAffected: db.RowsAffected,
+	}
+}
+
+// Unwrap returns the underlying *gorm.DB with the context bound to it.
+//
+// It serves as an escape hatch for gorm features not covered by DB.
+//
+// If the DB is a transaction started by Begin, the transaction span replaces the span of the context,
+// making the operations executed in the transaction its children.
+func (d *DB) Unwrap(ctx context.Context) *gorm.DB {
+	if rspan, ok := d.db.Get(transactionScopeKey); ok {
+		if span, ok := rspan.(*trace.Span); ok && span != nil {
+			ctx = trace.NewContext(ctx, span)
+		}
+	}
+
+	return WithContext(ctx, d.db)
+}
+
+// Where adds a search condition (see gorm.DB.Where).
+func (d *DB) Where(query interface{}, args ...interface{}) *DB {
+	return Wrap(d.db.Where(query, args...))
+}
+
+// Not adds a negated search condition (see gorm.DB.Not).
+func (d *DB) Not(query interface{}, args ...interface{}) *DB {
+	return Wrap(d.db.Not(query, args...))
+}
+
+// Or adds an OR search condition (see gorm.DB.Or).
+func (d *DB) Or(query interface{}, args ...interface{}) *DB {
+	return Wrap(d.db.Or(query, args...))
+}
+
+// Model specifies the model to run operations on (see gorm.DB.Model).
+func (d *DB) Model(value interface{}) *DB {
+	return Wrap(d.db.Model(value))
+}
+
+// Table specifies the table to run operations on (see gorm.DB.Table).
+func (d *DB) Table(name string) *DB {
+	return Wrap(d.db.Table(name))
+}
+
+// Select specifies the fields to retrieve (see gorm.DB.Select).
+func (d *DB) Select(query interface{}, args ...interface{}) *DB {
+	return Wrap(d.db.Select(query, args...))
+}
+
+// Order specifies the order of the retrieved records (see gorm.DB.Order).
+func (d *DB) Order(value interface{}, reorder ...bool) *DB {
+	return Wrap(d.db.Order(value, reorder...))
+}
+
+// Limit specifies the number of records to retrieve (see gorm.DB.Limit).
+func (d *DB) Limit(limit interface{}) *DB {
+	return Wrap(d.db.Limit(limit))
+}
+
+// Offset specifies the number of records to skip (see gorm.DB.Offset).
+func (d *DB) Offset(offset interface{}) *DB {
+	return Wrap(d.db.Offset(offset))
+}
+
+// Preload preloads associations (see gorm.DB.Preload).
+func (d *DB) Preload(column string, conditions ...interface{}) *DB {
+	return Wrap(d.db.Preload(column, conditions...))
+}
+
+// Unscoped includes soft deleted records in the operations (see gorm.DB.Unscoped).
+func (d *DB) Unscoped() *DB {
+	return Wrap(d.db.Unscoped())
+}
+
+// Create inserts a record (see gorm.DB.Create).
+func (d *DB) Create(ctx context.Context, value interface{}) *DB {
+	return Wrap(d.Unwrap(ctx).Create(value))
+}
+
+// Save updates a record or inserts it if it does not exist yet (see gorm.DB.Save).
+func (d *DB) Save(ctx context.Context, value interface{}) *DB {
+	return Wrap(d.Unwrap(ctx).Save(value))
+}
+
+// First finds the first record ordered by primary key (see gorm.DB.First).
+func (d *DB) First(ctx context.Context, out interface{}, where ...interface{}) *DB {
+	return Wrap(d.Unwrap(ctx).First(out, where...))
+}
+
+// Last finds the last record ordered by primary key (see gorm.DB.Last).
+func (d *DB) Last(ctx context.Context, out interface{}, where ...interface{}) *DB {
+	return Wrap(d.Unwrap(ctx).Last(out, where...))
+}
+
+// Find finds the records matching the given conditions (see gorm.DB.Find).
+func (d *DB) Find(ctx context.Context, out interface{}, where ...interface{}) *DB {
+	return Wrap(d.Unwrap(ctx).Find(out, where...))
+}
+
+// Count counts the records matching the current conditions (see gorm.DB.Count).
+func (d *DB) Count(ctx context.Context, value interface{}) *DB {
+	return Wrap(d.Unwrap(ctx).Count(value))
+}
+
+// Update updates attributes of the current model (see gorm.DB.Update).
+func (d *DB) Update(ctx context.Context, attrs ...interface{}) *DB {
+	return Wrap(d.Unwrap(ctx).Update(attrs...))
+}
+
+// Updates updates attributes of the current model (see gorm.DB.Updates).
+func (d *DB) Updates(ctx context.Context, values interface{}, ignoreProtectedAttrs ...bool) *DB {
+	return Wrap(d.Unwrap(ctx).Updates(values, ignoreProtectedAttrs...))
+}
+
+// Delete deletes the records matching the given conditions (see gorm.DB.Delete).
+func (d *DB) Delete(ctx context.Context, value interface{}, where ...interface{}) *DB {
+	return Wrap(d.Unwrap(ctx).Delete(value, where...))
+}
+
+// Transaction runs fn in a transaction (see Transaction).
+func (d *DB) Transaction(ctx context.Context, fn func(tx *DB) error) error {
+	return Transaction(ctx, d.db, func(tx *gorm.DB) error {
+		return fn(Wrap(tx))
+	})
+}
+
+// CreateContext inserts a record and returns the error (if any) returned by gorm (see gorm.DB.Create).
+func (d *DB) CreateContext(ctx context.Context, value interface{}) error {
+	return d.Create(ctx, value).Error
+}
+
+// FirstContext finds the first record ordered by primary key
+// and returns the error (if any) returned by gorm (see gorm.DB.First).
+func (d *DB) FirstContext(ctx context.Context, out interface{}, where ...interface{}) error {
+	return d.First(ctx, out, where...).Error
+}
+
+// FindContext finds the records matching the given conditions
+// and returns the error (if any) returned by gorm (see gorm.DB.Find).
+func (d *DB) FindContext(ctx context.Context, out interface{}, where ...interface{}) error {
+	return d.Find(ctx, out, where...).Error
+}
+
+// UpdatesContext updates attributes of the current model
+// and returns the error (if any) returned by gorm (see gorm.DB.Updates).
+func (d *DB) UpdatesContext(ctx context.Context, values interface{}, ignoreProtectedAttrs ...bool) error {
+	return d.Updates(ctx, values, ignoreProtectedAttrs...).Error
+}
+
+// DeleteContext deletes the records matching the given conditions
+// and returns the error (if any) returned by gorm (see gorm.DB.Delete).
+func (d *DB) DeleteContext(ctx context.Context, value interface{}, where ...interface{}) error {
+	return d.Delete(ctx, value, where...).Error
+}
+
+// TransactionContext runs fn in a transaction (see Transaction).
+func (d *DB) TransactionContext(ctx context.Context, fn func(tx *DB) error) error {
+	return d.Transaction(ctx, fn)
+}
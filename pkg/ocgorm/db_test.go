@@ -0,0 +1,291 @@
+package ocgorm_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"go.opencensus.io/trace"
+
+	"github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgorm"
+	"github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgorm/ocgormtest"
+)
+
+func TestDB(t *testing.T) {
+	tests := []struct {
+		name      string
+		operation string
+		span      string
+		run       func(ctx context.Context, db *ocgorm.DB) *ocgorm.DB
+	}{
+		{
+			name:      "create",
+			operation: ocgorm.OpCreate,
+			span:      "gorm:create",
+			run: func(ctx context.Context, db *ocgorm.DB) *ocgorm.DB {
+				return db.Create(ctx, &Person{FirstName: "Jane", LastName: "Doe"})
+			},
+		},
+		{
+			name:      "first",
+			operation: ocgorm.OpQuery,
+			span:      "gorm:query",
+			run: func(ctx context.Context, db *ocgorm.DB) *ocgorm.DB {
+				var person Person
+
+				return db.First(ctx, &person, "first_name = ?", "John")
+			},
+		},
+		{
+			name:      "find",
+			operation: ocgorm.OpQuery,
+			span:      "gorm:query",
+			run: func(ctx context.Context, db *ocgorm.DB) *ocgorm.DB {
+				var people []Person
+
+				return db.Where("last_name = ?", "Doe").Find(ctx, &people)
+			},
+		},
+		{
+			name:      "updates",
+			operation: ocgorm.OpUpdate,
+			span:      "gorm:update",
+			run: func(ctx context.Context, db *ocgorm.DB) *ocgorm.DB {
+				return db.Model(&Person{}).Where("first_name = ?", "John").Updates(ctx, map[string]interface{}{"last_name": "Smith"})
+			},
+		},
+		{
+			name:      "delete",
+			operation: ocgorm.OpDelete,
+			span:      "gorm:delete",
+			run: func(ctx context.Context, db *ocgorm.DB) *ocgorm.DB {
+				return db.Delete(ctx, &Person{}, "first_name = ?", "John")
+			},
+		},
+		{
+			name:      "unwrap",
+			operation: ocgorm.OpQuery,
+			span:      "gorm:query",
+			run: func(ctx context.Context, db *ocgorm.DB) *ocgorm.DB {
+				var people []Person
+
+				return ocgorm.Wrap(db.Unwrap(ctx).Find(&people))
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			db := newTestDB(t)
+
+			if err := db.Create(&Person{FirstName: "John", LastName: "Doe"}).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			// Views are registered after the setup so that only the tested operation is recorded
+			defer registerViews(t, ocgorm.SQLClientCallsView)()
+
+			exporter, unregister := ocgormtest.NewExporter()
+			defer unregister()
+
+			ctx, parent := trace.StartSpan(context.Background(), "parent")
+
+			result := test.run(ctx, ocgorm.Wrap(db))
+			if result.Error != nil {
+				t.Fatal(result.Error)
+			}
+
+			parent.End()
+
+			if result.RowsAffected != 1 {
+				t.Errorf("expected one affected row, got %d", result.RowsAffected)
+			}
+
+			span := findSpan(t, exporter, test.span, "people")
+
+			if span.ParentSpanID != parent.SpanContext().SpanID {
+				t.Error("the operation span is not a child of the parent span")
+			}
+
+			rows, err := ocgormtest.ViewRows(
+				ocgorm.SQLClientCallsView,
+				tag.Tag{Key: ocgorm.Operation, Value: test.operation},
+				tag.Tag{Key: ocgorm.Table, Value: "people"},
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(rows) != 1 {
+				t.Fatalf("expected one row, got %d", len(rows))
+			}
+
+			if data, ok := rows[0].Data.(*view.CountData); !ok || data.Value != 1 {
+				t.Errorf("expected one call, got %v", rows[0].Data)
+			}
+		})
+	}
+}
+
+func TestDB_Transaction(t *testing.T) {
+	db := newTestDB(t)
+
+	exporter, unregister := ocgormtest.NewExporter()
+	defer unregister()
+
+	ctx, parent := trace.StartSpan(context.Background(), "parent")
+
+	err := ocgorm.Wrap(db).Transaction(ctx, func(tx *ocgorm.DB) error {
+		return tx.Create(ctx, &Person{FirstName: "John", LastName: "Doe"}).Error
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parent.End()
+
+	var count int
+	if err := db.Model(&Person{}).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 1 {
+		t.Errorf("expected the transaction to be committed, got %d records", count)
+	}
+
+	txSpans := exporter.SpansWithName("gorm:transaction")
+	if len(txSpans) != 1 {
+		t.Fatalf("expected one transaction span, got %d", len(txSpans))
+	}
+
+	if txSpans[0].ParentSpanID != parent.SpanContext().SpanID {
+		t.Error("the transaction span is not a child of the parent span")
+	}
+
+	span := findSpan(t, exporter, "gorm:create", "people")
+
+	if span.ParentSpanID != txSpans[0].SpanID {
+		t.Error("the operation span is not a child of the transaction span")
+	}
+}
+
+func TestDB_ContextMethods(t *testing.T) {
+	tests := []struct {
+		name string
+		span string
+		run  func(ctx context.Context, db *ocgorm.DB) error
+	}{
+		{
+			name: "create",
+			span: "gorm:create",
+			run: func(ctx context.Context, db *ocgorm.DB) error {
+				return db.CreateContext(ctx, &Person{FirstName: "Jane", LastName: "Doe"})
+			},
+		},
+		{
+			name: "first",
+			span: "gorm:query",
+			run: func(ctx context.Context, db *ocgorm.DB) error {
+				var person Person
+
+				return db.FirstContext(ctx, &person, "first_name = ?", "John")
+			},
+		},
+		{
+			name: "find",
+			span: "gorm:query",
+			run: func(ctx context.Context, db *ocgorm.DB) error {
+				var people []Person
+
+				return db.FindContext(ctx, &people, "last_name = ?", "Doe")
+			},
+		},
+		{
+			name: "updates",
+			span: "gorm:update",
+			run: func(ctx context.Context, db *ocgorm.DB) error {
+				return db.Model(&Person{}).Where("first_name = ?", "John").UpdatesContext(ctx, map[string]interface{}{"last_name": "Smith"})
+			},
+		},
+		{
+			name: "delete",
+			span: "gorm:delete",
+			run: func(ctx context.Context, db *ocgorm.DB) error {
+				return db.DeleteContext(ctx, &Person{}, "first_name = ?", "John")
+			},
+		},
+		{
+			name: "transaction",
+			span: "gorm:transaction",
+			run: func(ctx context.Context, db *ocgorm.DB) error {
+				return db.TransactionContext(ctx, func(tx *ocgorm.DB) error {
+					return nil
+				})
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			db := newTestDB(t)
+
+			if err := db.Create(&Person{FirstName: "John", LastName: "Doe"}).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			exporter, unregister := ocgormtest.NewExporter()
+			defer unregister()
+
+			ctx, parent := trace.StartSpan(context.Background(), "parent")
+
+			if err := test.run(ctx, ocgorm.Wrap(db)); err != nil {
+				t.Fatal(err)
+			}
+
+			parent.End()
+
+			spans := exporter.SpansWithName(test.span)
+			if len(spans) != 1 {
+				t.Fatalf("expected one %s span, got %d", test.span, len(spans))
+			}
+
+			if spans[0].ParentSpanID != parent.SpanContext().SpanID {
+				t.Error("the operation span is not a child of the parent span")
+			}
+		})
+	}
+}
+
+func TestDB_ContextMethods_Error(t *testing.T) {
+	db := ocgorm.Wrap(newTestDB(t))
+
+	var person Person
+
+	if err := db.FirstContext(context.Background(), &person, "first_name = ?", "John"); err != gorm.ErrRecordNotFound {
+		t.Errorf("expected %v, got %v", gorm.ErrRecordNotFound, err)
+	}
+
+	var people []Person
+
+	err := db.FindContext(context.Background(), &people, "missing_column = ?", "John")
+	if err == nil {
+		t.Fatal("expected an error for an invalid query")
+	}
+
+	if expected := db.Find(context.Background(), &people, "missing_column = ?", "John").Error; err.Error() != expected.Error() {
+		t.Errorf("expected the error returned by gorm (%v), got %v", expected, err)
+	}
+
+	txErr := errors.New("rollback")
+
+	if err := db.TransactionContext(context.Background(), func(tx *ocgorm.DB) error { return txErr }); err != txErr {
+		t.Errorf("expected the error returned by the function, got %v", err)
+	}
+}
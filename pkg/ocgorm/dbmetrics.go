@@ -0,0 +1,89 @@
+package ocgorm
+
+import (
+	"github.com/jinzhu/gorm"
+	"go.opencensus.io/metric"
+	"go.opencensus.io/metric/metricdata"
+	"go.opencensus.io/metric/metricproducer"
+)
+
+// RegisterStatsProducer registers a metric producer reading database connection pool statistics
+// whenever exporters collect metrics, so the exported values are never stale.
+// The returned function unregisters the producer.
+//
+// Unlike RecordStats, it does not record measurements, so the connection pool views are not populated:
+// the exporter has to support reading metrics from producers (see go.opencensus.io/metric/metricproducer).
+//
+// An error is returned (and nothing is registered) if the db instance has no connection pool (eg. it is a transaction).
+//
+// Pass DatabaseName as an option to tell the stats of multiple databases apart.
+func RegisterStatsProducer(db *gorm.DB, opts ...StatsOption) (func(), error) {
+	sqlDB := db.DB()
+	if sqlDB == nil {
+		return nil, errNoConnectionPool
+	}
+
+	c := newStatsConfig(opts...)
+
+	var database metricdata.LabelValue
+	if c.databaseName != "" {
+		database = metricdata.NewLabelValue(c.databaseName)
+	}
+
+	registry := metric.NewRegistry()
+
+	for _, s := range poolStats {
+		s := s
+
+		entry := func() int64 { return s.value(sqlDB.Stats()) }
+
+		options := []metric.Options{
+			metric.WithDescription(s.measure.Description()),
+			metric.WithUnit(metricdata.UnitDimensionless),
+			metric.WithLabelKeys(Database.Name()),
+		}
+
+		if s.cumulative {
+			cumulative, err := registry.AddInt64DerivedCumulative(s.measure.Name(), options...)
+			if err != nil {
+				return nil, err
+			}
+
+			if err := cumulative.UpsertEntry(entry, database); err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+
+		gauge, err := registry.AddInt64DerivedGauge(s.measure.Name(), options...)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := gauge.UpsertEntry(entry, database); err != nil {
+			return nil, err
+		}
+	}
+
+	waitDuration, err := registry.AddFloat64DerivedCumulative(
+		MeasureWaitDuration.Name(),
+		metric.WithDescription(MeasureWaitDuration.Description()),
+		metric.WithUnit(metricdata.UnitMilliseconds),
+		metric.WithLabelKeys(Database.Name()),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	err = waitDuration.UpsertEntry(func() float64 { return waitDurationMillis(sqlDB.Stats()) }, database)
+	if err != nil {
+		return nil, err
+	}
+
+	metricproducer.GlobalManager().AddProducer(registry)
+
+	return func() {
+		metricproducer.GlobalManager().DeleteProducer(registry)
+	}, nil
+}
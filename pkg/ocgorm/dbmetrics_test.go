@@ -0,0 +1,65 @@
+package ocgorm_test
+
+import (
+	"testing"
+
+	"go.opencensus.io/metric/metricdata"
+	"go.opencensus.io/metric/metricproducer"
+
+	"github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgorm"
+)
+
+func TestRegisterStatsProducer(t *testing.T) {
+	db := newTestDB(t)
+
+	unregister, err := ocgorm.RegisterStatsProducer(db, ocgorm.DatabaseName("producer"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unregister()
+
+	metrics := make(map[string]*metricdata.Metric)
+
+	for _, producer := range metricproducer.GlobalManager().GetAll() {
+		for _, m := range producer.Read() {
+			metrics[m.Descriptor.Name] = m
+		}
+	}
+
+	maxOpen, ok := metrics[ocgorm.MeasureMaxOpenConnections.Name()]
+	if !ok {
+		t.Fatal("the max open connections metric is not produced")
+	}
+
+	if maxOpen.Descriptor.Type != metricdata.TypeGaugeInt64 {
+		t.Errorf("expected a gauge, got %v", maxOpen.Descriptor.Type)
+	}
+
+	if value := maxOpen.TimeSeries[0].Points[0].Value; value != int64(1) {
+		t.Errorf("expected one max open connection, got %v", value)
+	}
+
+	if label := maxOpen.TimeSeries[0].LabelValues[0].Value; label != "producer" {
+		t.Errorf("expected the database name label, got %q", label)
+	}
+
+	waitCount, ok := metrics[ocgorm.MeasureWaitCount.Name()]
+	if !ok {
+		t.Fatal("the wait count metric is not produced")
+	}
+
+	if waitCount.Descriptor.Type != metricdata.TypeCumulativeInt64 {
+		t.Errorf("expected a cumulative metric, got %v", waitCount.Descriptor.Type)
+	}
+}
+
+func TestRegisterStatsProducer_NoConnectionPool(t *testing.T) {
+	db := newTestDB(t)
+
+	tx := db.Begin()
+	defer tx.Rollback()
+
+	if _, err := ocgorm.RegisterStatsProducer(tx); err == nil {
+		t.Error("expected an error for a db instance without a connection pool")
+	}
+}
@@ -0,0 +1,393 @@
+package ocgorm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+// Connection pool measures
+var (
+	MeasureMaxOpenConnections = stats.Int64("go.sql/db/connections/max_open", "The maximum number of open connections", stats.UnitDimensionless)
+	MeasureOpenConnections    = stats.Int64("go.sql/db/connections/open", "The number of open connections", stats.UnitDimensionless)
+	MeasureIdleConnections    = stats.Int64("go.sql/db/connections/idle", "The number of idle connections", stats.UnitDimensionless)
+	MeasureActiveConnections  = stats.Int64("go.sql/db/connections/active", "The number of active connections", stats.UnitDimensionless)
+	MeasureWaitCount          = stats.Int64("go.sql/db/connections/wait_count", "The total number of connections waited for", stats.UnitDimensionless)
+	MeasureWaitDuration       = stats.Float64("go.sql/db/connections/wait_duration", "The total time blocked waiting for a new connection", stats.UnitMilliseconds)
+	MeasureIdleClosed         = stats.Int64("go.sql/db/connections/idle_closed", "The total number of connections closed due to SetMaxIdleConns", stats.UnitDimensionless)
+	MeasureLifetimeClosed     = stats.Int64("go.sql/db/connections/lifetime_closed", "The total number of connections closed due to SetConnMaxLifetime", stats.UnitDimensionless)
+
+	// Result of the ping of the database (recorded by RecordStats)
+	MeasureDBUp = stats.Int64("go.sql/db/up", "Whether the database can be reached (1) or not (0)", stats.UnitDimensionless)
+
+	// Increase of the wait counters since the previous sample (recorded by RecordStats)
+	MeasureWaitCountDelta    = stats.Int64("go.sql/db/connections/wait_count_delta", "The number of connections waited for since the previous sample", stats.UnitDimensionless)
+	MeasureWaitDurationDelta = stats.Float64("go.sql/db/connections/wait_duration_delta", "The time blocked waiting for a new connection since the previous sample", stats.UnitMilliseconds)
+)
+
+// Connection pool views
+var (
+	SQLClientMaxOpenConnectionsView = &view.View{
+		Name:        "go.sql/db/connections/max_open",
+		Description: "The maximum number of open connections",
+		Measure:     MeasureMaxOpenConnections,
+		TagKeys:     []tag.Key{Database},
+		Aggregation: view.LastValue(),
+	}
+
+	SQLClientOpenConnectionsView = &view.View{
+		Name:        "go.sql/db/connections/open",
+		Description: "The number of open connections",
+		Measure:     MeasureOpenConnections,
+		TagKeys:     []tag.Key{Database},
+		Aggregation: view.LastValue(),
+	}
+
+	SQLClientIdleConnectionsView = &view.View{
+		Name:        "go.sql/db/connections/idle",
+		Description: "The number of idle connections",
+		Measure:     MeasureIdleConnections,
+		TagKeys:     []tag.Key{Database},
+		Aggregation: view.LastValue(),
+	}
+
+	SQLClientActiveConnectionsView = &view.View{
+		Name:        "go.sql/db/connections/active",
+		Description: "The number of active connections",
+		Measure:     MeasureActiveConnections,
+		TagKeys:     []tag.Key{Database},
+		Aggregation: view.LastValue(),
+	}
+
+	SQLClientWaitCountView = &view.View{
+		Name:        "go.sql/db/connections/wait_count",
+		Description: "The total number of connections waited for",
+		Measure:     MeasureWaitCount,
+		TagKeys:     []tag.Key{Database},
+		Aggregation: view.LastValue(),
+	}
+
+	SQLClientWaitDurationView = &view.View{
+		Name:        "go.sql/db/connections/wait_duration",
+		Description: "The total time blocked waiting for a new connection",
+		Measure:     MeasureWaitDuration,
+		TagKeys:     []tag.Key{Database},
+		Aggregation: view.LastValue(),
+	}
+
+	SQLClientIdleClosedView = &view.View{
+		Name:        "go.sql/db/connections/idle_closed",
+		Description: "The total number of connections closed due to SetMaxIdleConns",
+		Measure:     MeasureIdleClosed,
+		TagKeys:     []tag.Key{Database},
+		Aggregation: view.LastValue(),
+	}
+
+	SQLClientLifetimeClosedView = &view.View{
+		Name:        "go.sql/db/connections/lifetime_closed",
+		Description: "The total number of connections closed due to SetConnMaxLifetime",
+		Measure:     MeasureLifetimeClosed,
+		TagKeys:     []tag.Key{Database},
+		Aggregation: view.LastValue(),
+	}
+
+	SQLClientDBUpView = &view.View{
+		Name:        "go.sql/db/up",
+		Description: "Whether the database can be reached (1) or not (0)",
+		Measure:     MeasureDBUp,
+		TagKeys:     []tag.Key{Database},
+		Aggregation: view.LastValue(),
+	}
+
+	// SQLClientWaitCountTotalView is the cumulative alternative of SQLClientWaitCountView.
+	SQLClientWaitCountTotalView = &view.View{
+		Name:        "go.sql/db/connections/wait_count_total",
+		Description: "The total number of connections waited for",
+		Measure:     MeasureWaitCountDelta,
+		TagKeys:     []tag.Key{Database},
+		Aggregation: view.Sum(),
+	}
+
+	// SQLClientWaitDurationTotalView is the cumulative alternative of SQLClientWaitDurationView.
+	SQLClientWaitDurationTotalView = &view.View{
+		Name:        "go.sql/db/connections/wait_duration_total",
+		Description: "The total time blocked waiting for a new connection",
+		Measure:     MeasureWaitDurationDelta,
+		TagKeys:     []tag.Key{Database},
+		Aggregation: view.Sum(),
+	}
+)
+
+// CumulativeConnectionViews contains views exposing the wait counters of the connection pool as cumulative sums.
+// Unlike the LastValue views, they can be used with rate functions (eg. in Prometheus).
+//
+// Only RecordStats records the measures of these views.
+var CumulativeConnectionViews = []*view.View{
+	SQLClientWaitCountTotalView,
+	SQLClientWaitDurationTotalView,
+}
+
+// StatsOption allows for managing connection pool stats recording configuration using functional options.
+type StatsOption interface {
+	applyStats(c *statsConfig)
+}
+
+// StatsOptionFunc converts a regular function to a StatsOption if it's definition is compatible.
+type StatsOptionFunc func(c *statsConfig)
+
+func (fn StatsOptionFunc) applyStats(c *statsConfig) {
+	fn(c)
+}
+
+func (d DatabaseName) applyStats(c *statsConfig) {
+	c.databaseName = string(d)
+}
+
+// defaultPingTimeout is the default timeout of the pings of RecordStats.
+const defaultPingTimeout = time.Second
+
+// PingTimeout sets the timeout of the database pings of RecordStats (see MeasureDBUp).
+// Zero disables pinging the database. Defaults to one second.
+type PingTimeout time.Duration
+
+func (p PingTimeout) applyStats(c *statsConfig) {
+	c.pingTimeout = time.Duration(p)
+}
+
+type statsConfig struct {
+	// Name of the database instance recorded with each measurement.
+	databaseName string
+
+	// Timeout of the database pings.
+	// Zero disables pinging the database.
+	pingTimeout time.Duration
+}
+
+// newStatsConfig returns a new stats configuration with the defaults and the options applied.
+func newStatsConfig(opts ...StatsOption) *statsConfig {
+	c := &statsConfig{
+		pingTimeout: defaultPingTimeout,
+	}
+
+	for _, opt := range opts {
+		opt.applyStats(c)
+	}
+
+	return c
+}
+
+// poolStat is an integer connection pool statistic,
+// recorded as a measurement by RecordStats and read as a metric by RegisterStatsProducer.
+type poolStat struct {
+	measure *stats.Int64Measure
+
+	// Monotonically increasing statistics are exposed as cumulative metrics.
+	cumulative bool
+
+	value func(dbStats sql.DBStats) int64
+}
+
+// poolStats contains the integer connection pool statistics (the wait duration is handled separately).
+var poolStats = []poolStat{
+	{MeasureMaxOpenConnections, false, func(s sql.DBStats) int64 { return int64(s.MaxOpenConnections) }},
+	{MeasureOpenConnections, false, func(s sql.DBStats) int64 { return int64(s.OpenConnections) }},
+	{MeasureIdleConnections, false, func(s sql.DBStats) int64 { return int64(s.Idle) }},
+	{MeasureActiveConnections, false, func(s sql.DBStats) int64 { return int64(s.InUse) }},
+	{MeasureWaitCount, true, func(s sql.DBStats) int64 { return s.WaitCount }},
+	{MeasureIdleClosed, true, func(s sql.DBStats) int64 { return s.MaxIdleClosed }},
+	{MeasureLifetimeClosed, true, func(s sql.DBStats) int64 { return s.MaxLifetimeClosed }},
+}
+
+// waitDurationMillis returns the total time blocked waiting for a new connection in milliseconds.
+func waitDurationMillis(dbStats sql.DBStats) float64 {
+	return float64(dbStats.WaitDuration) / float64(time.Millisecond)
+}
+
+// ConnectionViews contains the views recommended to register for connection pool stats (see RecordStats).
+var ConnectionViews = []*view.View{
+	SQLClientMaxOpenConnectionsView,
+	SQLClientOpenConnectionsView,
+	SQLClientIdleConnectionsView,
+	SQLClientActiveConnectionsView,
+	SQLClientWaitCountView,
+	SQLClientWaitDurationView,
+	SQLClientIdleClosedView,
+	SQLClientLifetimeClosedView,
+	SQLClientDBUpView,
+}
+
+// errNoConnectionPool is returned when the db instance has no connection pool (eg. it is a transaction).
+var errNoConnectionPool = errors.New("ocgorm: db instance has no connection pool (eg. it is a transaction)")
+
+// CollectStats records database connection pool statistics once.
+//
+// It is useful for pull based setups, where stats are collected right before being exported.
+// Tags in the context (eg. Database) are recorded with the measurements.
+//
+// An error is returned (and nothing is recorded) if the db instance has no connection pool (eg. it is a transaction).
+func CollectStats(ctx context.Context, db *gorm.DB) error {
+	sqlDB := db.DB()
+	if sqlDB == nil {
+		return errNoConnectionPool
+	}
+
+	collectStats(ctx, sqlDB.Stats())
+
+	return nil
+}
+
+func collectStats(ctx context.Context, dbStats sql.DBStats) {
+	measurements := make([]stats.Measurement, 0, len(poolStats)+1)
+
+	for _, s := range poolStats {
+		measurements = append(measurements, s.measure.M(s.value(dbStats)))
+	}
+
+	measurements = append(measurements, MeasureWaitDuration.M(waitDurationMillis(dbStats)))
+
+	stats.Record(ctx, measurements...)
+}
+
+// RecordStats records database connection pool statistics at the provided interval.
+// The first sample is recorded immediately. The returned function stops recording.
+//
+// Recording on a ticker is kept for exporters reading views only:
+// RegisterStatsProducer reads the same statistics lazily, when exporters collect metrics.
+func RecordStats(db *gorm.DB, interval time.Duration, opts ...StatsOption) (func(), error) {
+	return RecordStatsWithContext(context.Background(), db, interval, opts...)
+}
+
+// RecordStatsWithContext records database connection pool statistics at the provided interval.
+// The first sample is recorded immediately.
+// Recording stops when the context is done or the returned function is called.
+//
+// The database is pinged in the background with a short timeout (see PingTimeout) at each interval as well,
+// starting right away: the result is recorded in MeasureDBUp (1 on success, 0 on failure, see SQLClientDBUpView).
+// Recording never stops because of the database: it continues through outages and reconnects,
+// which are visible in SQLClientDBUpView instead.
+//
+// Pass DatabaseName as an option to tell the stats of multiple databases apart.
+func RecordStatsWithContext(ctx context.Context, db *gorm.DB, interval time.Duration, opts ...StatsOption) (func(), error) {
+	if interval <= 0 {
+		return nil, errors.New("ocgorm: stats recording interval must be positive")
+	}
+
+	// The connection pool is resolved (and checked) once, so that collecting never reads a nil pool
+	sqlDB := db.DB()
+	if sqlDB == nil {
+		return nil, errNoConnectionPool
+	}
+
+	c := newStatsConfig(opts...)
+
+	recordCtx := ctx
+	if c.databaseName != "" {
+		var err error
+
+		recordCtx, err = tag.New(ctx, tag.Upsert(Database, c.databaseName))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var prevStats sql.DBStats
+
+	collect := func() {
+		dbStats := sqlDB.Stats()
+
+		collectStats(recordCtx, dbStats)
+
+		stats.Record(
+			recordCtx,
+			MeasureWaitCountDelta.M(dbStats.WaitCount-prevStats.WaitCount),
+			MeasureWaitDurationDelta.M(waitDurationMillis(dbStats)-waitDurationMillis(prevStats)),
+		)
+
+		prevStats = dbStats
+	}
+
+	// Pings are canceled when recording stops
+	pingCtx, cancelPing := context.WithCancel(recordCtx)
+
+	ping := func() {
+		if c.pingTimeout > 0 {
+			recordDBUp(pingCtx, sqlDB, c.pingTimeout)
+		}
+	}
+
+	// Record the first sample right away instead of waiting for the first tick
+	collect()
+
+	var (
+		closeOnce sync.Once
+		ticker    = time.NewTicker(interval)
+		done      = make(chan struct{})
+		stopped   = make(chan struct{})
+	)
+
+	go func() {
+		defer close(stopped)
+		defer ticker.Stop()
+
+		// The first ping does not block the caller (eg. when the database is unreachable)
+		ping()
+
+		for {
+			select {
+			case <-ticker.C:
+				collect()
+				ping()
+
+			case <-ctx.Done():
+				return
+
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	// Nothing is recorded once the returned function returns (eg. the database can be closed safely)
+	return func() {
+		closeOnce.Do(func() {
+			cancelPing()
+			close(done)
+		})
+
+		<-stopped
+	}, nil
+}
+
+// recordDBUp pings the database and records whether it can be reached.
+// A missing connection pool is recorded as unreachable.
+func recordDBUp(ctx context.Context, sqlDB *sql.DB, timeout time.Duration) {
+	// Recording is being stopped, the database is not necessarily down
+	if ctx.Err() != nil {
+		return
+	}
+
+	var up int64
+
+	if sqlDB != nil {
+		pingCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		if err := sqlDB.PingContext(pingCtx); err == nil {
+			up = 1
+		}
+	}
+
+	// The ping was interrupted by stopping the recording
+	if ctx.Err() != nil {
+		return
+	}
+
+	stats.Record(ctx, MeasureDBUp.M(up))
+}
@@ -0,0 +1,181 @@
+package ocgorm_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"go.opencensus.io/stats/view"
+
+	"github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgorm"
+	"github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgorm/ocgormtest"
+)
+
+func TestCollectStats_NoConnectionPool(t *testing.T) {
+	db := newTestDB(t)
+
+	tx := db.Begin()
+	defer tx.Rollback()
+
+	if err := ocgorm.CollectStats(context.Background(), tx); err == nil {
+		t.Error("expected an error for a db instance without a connection pool")
+	}
+}
+
+func TestRecordStatsWithContext_Validation(t *testing.T) {
+	db := newTestDB(t)
+
+	if _, err := ocgorm.RecordStatsWithContext(context.Background(), db, 0); err == nil {
+		t.Error("expected an error for a non-positive interval")
+	}
+
+	tx := db.Begin()
+	defer tx.Rollback()
+
+	if _, err := ocgorm.RecordStatsWithContext(context.Background(), tx, time.Second); err == nil {
+		t.Error("expected an error for a db instance without a connection pool")
+	}
+}
+
+func TestRecordStats_FirstSample(t *testing.T) {
+	defer registerViews(t, ocgorm.SQLClientMaxOpenConnectionsView)()
+
+	db := newTestDB(t)
+
+	// The first tick would only come after an hour
+	stop, err := ocgorm.RecordStats(db, time.Hour, ocgorm.PingTimeout(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	rows, err := ocgormtest.ViewRows(ocgorm.SQLClientMaxOpenConnectionsView)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) != 1 {
+		t.Fatalf("expected the first sample to be recorded when recording starts, got %d rows", len(rows))
+	}
+
+	if value := rows[0].Data.(*view.LastValueData).Value; value != 1 {
+		t.Errorf("expected one max open connection, got %f", value)
+	}
+}
+
+// flakyDown makes the connections of the flaky driver fail to ping (when 1) or hang until the ping is canceled (when 2).
+var flakyDown int32
+
+func init() {
+	sql.Register("ocgorm-flaky", flakyDriver{})
+}
+
+// flakyDriver is a driver whose database can be taken down (see flakyDown).
+type flakyDriver struct{}
+
+func (flakyDriver) Open(name string) (driver.Conn, error) { return flakyConn{}, nil }
+
+type flakyConn struct{}
+
+func (flakyConn) Prepare(query string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (flakyConn) Close() error                              { return nil }
+func (flakyConn) Begin() (driver.Tx, error)                 { return nil, errors.New("not supported") }
+
+func (flakyConn) Ping(ctx context.Context) error {
+	switch atomic.LoadInt32(&flakyDown) {
+	case 1:
+		return errors.New("database is down")
+
+	case 2:
+		<-ctx.Done()
+
+		return ctx.Err()
+	}
+
+	return nil
+}
+
+// waitForLastValue waits for the single row of a LastValue view to have the given value.
+func waitForLastValue(t *testing.T, v *view.View, value float64) {
+	t.Helper()
+
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		rows, err := ocgormtest.ViewRows(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(rows) == 1 && rows[0].Data.(*view.LastValueData).Value == value {
+			return
+		}
+	}
+
+	t.Fatalf("%s did not become %f", v.Name, value)
+}
+
+func TestRecordStats_DatabaseOutage(t *testing.T) {
+	defer registerViews(t, ocgorm.SQLClientDBUpView)()
+
+	sqlDB, err := sql.Open("ocgorm-flaky", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := gorm.Open("sqlite3", sqlDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	stop, err := ocgorm.RecordStats(db, 5*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	waitForLastValue(t, ocgorm.SQLClientDBUpView, 1)
+
+	atomic.StoreInt32(&flakyDown, 1)
+
+	waitForLastValue(t, ocgorm.SQLClientDBUpView, 0)
+
+	// Recording continues through the outage
+	atomic.StoreInt32(&flakyDown, 0)
+
+	waitForLastValue(t, ocgorm.SQLClientDBUpView, 1)
+}
+
+func TestRecordStats_UnreachableDatabase(t *testing.T) {
+	sqlDB, err := sql.Open("ocgorm-flaky", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := gorm.Open("sqlite3", sqlDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	atomic.StoreInt32(&flakyDown, 2)
+	defer atomic.StoreInt32(&flakyDown, 0)
+
+	start := time.Now()
+
+	// Neither starting nor stopping the recording waits for the ping
+	stop, err := ocgorm.RecordStats(db, time.Hour, ocgorm.PingTimeout(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stop()
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected recording to start and stop without waiting for the ping, took %s", elapsed)
+	}
+}
@@ -0,0 +1,78 @@
+// Package mysql contains MySQL specific helpers for ocgorm.
+package mysql
+
+import (
+	"net"
+	"strconv"
+
+	"github.com/go-sql-driver/mysql"
+	"go.opencensus.io/trace"
+
+	"github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgorm"
+)
+
+// ClassifyError maps MySQL driver errors to trace status codes.
+//
+// See https://dev.mysql.com/doc/refman/8.0/en/server-error-reference.html
+func ClassifyError(err error) (int32, bool) {
+	if err == mysql.ErrInvalidConn {
+		return trace.StatusCodeUnavailable, true
+	}
+
+	merr, ok := err.(*mysql.MySQLError)
+	if !ok {
+		return 0, false
+	}
+
+	switch merr.Number {
+	case 1062: // ER_DUP_ENTRY
+		return trace.StatusCodeAlreadyExists, true
+
+	case 1213: // ER_LOCK_DEADLOCK
+		return trace.StatusCodeAborted, true
+
+	case 1205: // ER_LOCK_WAIT_TIMEOUT
+		return trace.StatusCodeDeadlineExceeded, true
+
+	case 1040, // ER_CON_COUNT_ERROR
+		1053, // ER_SERVER_SHUTDOWN
+		1152, // ER_ABORTING_CONNECTION
+		1158, // ER_NET_READ_ERROR
+		1159, // ER_NET_READ_INTERRUPTED
+		1160, // ER_NET_ERROR_ON_WRITE
+		1161: // ER_NET_WRITE_INTERRUPTED
+		return trace.StatusCodeUnavailable, true
+
+	case 1064, // ER_PARSE_ERROR
+		1149: // ER_SYNTAX_ERROR
+		return trace.StatusCodeInvalidArgument, true
+
+	case 1451, // ER_ROW_IS_REFERENCED_2
+		1452: // ER_NO_REFERENCED_ROW_2
+		return trace.StatusCodeFailedPrecondition, true
+	}
+
+	return 0, false
+}
+
+// DatabaseInfo parses a MySQL DSN and returns an ocgorm.DatabaseInfo option
+// with the database name and server address found in it.
+func DatabaseInfo(dsn string) (ocgorm.Option, error) {
+	config, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	var host string
+	var port int
+
+	if config.Net == "tcp" {
+		h, p, err := net.SplitHostPort(config.Addr)
+		if err == nil {
+			host = h
+			port, _ = strconv.Atoi(p)
+		}
+	}
+
+	return ocgorm.DatabaseInfo(config.DBName, host, port), nil
+}
@@ -0,0 +1,45 @@
+package mysql
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"go.opencensus.io/trace"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		code       int32
+		classified bool
+	}{
+		{"duplicate entry", &mysql.MySQLError{Number: 1062, Message: "Duplicate entry"}, trace.StatusCodeAlreadyExists, true},
+		{"deadlock", &mysql.MySQLError{Number: 1213, Message: "Deadlock found"}, trace.StatusCodeAborted, true},
+		{"lock wait timeout", &mysql.MySQLError{Number: 1205, Message: "Lock wait timeout exceeded"}, trace.StatusCodeDeadlineExceeded, true},
+		{"too many connections", &mysql.MySQLError{Number: 1040, Message: "Too many connections"}, trace.StatusCodeUnavailable, true},
+		{"server shutdown", &mysql.MySQLError{Number: 1053, Message: "Server shutdown in progress"}, trace.StatusCodeUnavailable, true},
+		{"parse error", &mysql.MySQLError{Number: 1064, Message: "You have an error in your SQL syntax"}, trace.StatusCodeInvalidArgument, true},
+		{"foreign key", &mysql.MySQLError{Number: 1452, Message: "Cannot add or update a child row"}, trace.StatusCodeFailedPrecondition, true},
+		{"invalid connection", mysql.ErrInvalidConn, trace.StatusCodeUnavailable, true},
+		{"unknown mysql error", &mysql.MySQLError{Number: 1146, Message: "Table doesn't exist"}, 0, false},
+		{"other error", errors.New("error"), 0, false},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			code, classified := ClassifyError(test.err)
+
+			if classified != test.classified {
+				t.Fatalf("expected classified to be %t, got %t", test.classified, classified)
+			}
+
+			if code != test.code {
+				t.Errorf("expected status code %d, got %d", test.code, code)
+			}
+		})
+	}
+}
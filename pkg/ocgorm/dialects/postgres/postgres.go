@@ -0,0 +1,75 @@
+// Package postgres contains PostgreSQL specific helpers for ocgorm.
+package postgres
+
+import (
+	"github.com/lib/pq"
+	"go.opencensus.io/trace"
+)
+
+// sqlStateError is implemented by errors exposing the PostgreSQL error code (eg. pgx).
+type sqlStateError interface {
+	SQLState() string
+}
+
+// ClassifyError maps lib/pq and pgx errors to trace status codes.
+//
+// See https://www.postgresql.org/docs/current/errcodes-appendix.html
+func ClassifyError(err error) (int32, bool) {
+	var code string
+
+	switch e := err.(type) {
+	case *pq.Error:
+		code = string(e.Code)
+
+	case pq.Error:
+		code = string(e.Code)
+
+	case sqlStateError:
+		code = e.SQLState()
+
+	default:
+		return 0, false
+	}
+
+	switch code {
+	case "23505": // unique_violation
+		return trace.StatusCodeAlreadyExists, true
+
+	case "40P01", // deadlock_detected
+		"40001": // serialization_failure
+		return trace.StatusCodeAborted, true
+
+	case "57014": // query_canceled
+		return trace.StatusCodeCancelled, true
+
+	case "55P03": // lock_not_available
+		return trace.StatusCodeDeadlineExceeded, true
+
+	case "23503", // foreign_key_violation
+		"23514": // check_violation
+		return trace.StatusCodeFailedPrecondition, true
+
+	case "57P01", // admin_shutdown
+		"57P02", // crash_shutdown
+		"57P03": // cannot_connect_now
+		return trace.StatusCodeUnavailable, true
+	}
+
+	if len(code) != 5 {
+		return 0, false
+	}
+
+	switch code[:2] {
+	case "08": // connection_exception
+		return trace.StatusCodeUnavailable, true
+
+	case "53": // insufficient_resources (eg. too_many_connections)
+		return trace.StatusCodeResourceExhausted, true
+
+	case "22", // data_exception
+		"42": // syntax_error_or_access_rule_violation
+		return trace.StatusCodeInvalidArgument, true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,349 @@
+package ocgorm
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/jinzhu/gorm"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/trace"
+)
+
+// DisableDriver disables the instrumented database/sql driver layer of Open.
+// Only the callbacks are registered in this case.
+type DisableDriver bool
+
+func (d DisableDriver) apply(c *callbacks) {
+	c.disableDriver = bool(d)
+}
+
+// Open opens a database using an instrumented database/sql driver and registers the callbacks.
+// The dialect is used as the name of the underlying database/sql driver (as in gorm.Open).
+//
+// The driver layer records sql:* spans (eg. sql:query, sql:exec) for the statements sent to the database.
+// Note that gorm executes statements without a context, so the spans of statements executed by gorm operations
+// are not children of the gorm spans: they are only recorded (as root spans) when AllowRoot is enabled.
+// Calls passing a context to the underlying sql.DB (eg. Ping or queries instrumented by StartSpan)
+// are recorded as children of the span in the context.
+// The batch attributes and sizes of multi-row INSERT statements executed directly (eg. by gorm.DB.Exec) are recorded as well.
+//
+// Options are applied to both the driver layer and the callbacks.
+// Each call registers a new instrumented database/sql driver, so that each database uses its own options.
+func Open(dialect string, dsn string, opts ...Option) (*gorm.DB, error) {
+	c := newCallbacks(opts...)
+
+	var db *gorm.DB
+	var err error
+
+	if c.disableDriver {
+		db, err = gorm.Open(dialect, dsn)
+	} else {
+		var driverName string
+
+		c.dialect = dialect
+
+		driverName, err = registerDriver(dialect, dsn, c)
+		if err != nil {
+			return nil, err
+		}
+
+		db, err = gorm.Open(dialect, driverName, dsn)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	RegisterCallbacks(db, opts...)
+
+	return db, nil
+}
+
+var errNamedParameters = errors.New("ocgorm: driver does not support named parameters")
+
+// driverCount is the number of registered instrumented drivers.
+var driverCount uint64
+
+// registerDriver registers an instrumented version of a database/sql driver configured by c and returns its name.
+func registerDriver(driverName string, dsn string, c *callbacks) (string, error) {
+	// The only way to access a registered driver is through an sql.DB (no connection is opened)
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return "", err
+	}
+
+	d := db.Driver()
+
+	_ = db.Close()
+
+	name := fmt.Sprintf("ocgorm-%s-%d", driverName, atomic.AddUint64(&driverCount, 1))
+
+	sql.Register(name, &instrumentedDriver{parent: d, c: c})
+
+	return name, nil
+}
+
+// startDriverSpan starts a span for a driver operation if there is a parent span or root spans are allowed.
+func (c *callbacks) startDriverSpan(ctx context.Context, name string, query string) (context.Context, *trace.Span) {
+	if c.disableTrace || (trace.FromContext(ctx) == nil && !c.allowRoot) {
+		return ctx, nil
+	}
+
+	ctx, span := trace.StartSpan(ctx, name, trace.WithSpanKind(c.spanKind), trace.WithSampler(c.spanSampler()))
+
+	span.AddAttributes(c.defaultAttributes...)
+
+	if c.recordsQueries() && query != "" {
+		span.AddAttributes(trace.StringAttribute(QueryAttribute, c.processQuery(query)))
+	}
+
+	return ctx, span
+}
+
+// endDriverSpan ends a span started by startDriverSpan.
+func (c *callbacks) endDriverSpan(span *trace.Span, err error) {
+	if span == nil {
+		return
+	}
+
+	if err != nil && err != driver.ErrSkip {
+		span.SetStatus(c.statusFromError(err))
+	}
+
+	span.End()
+}
+
+// recordBatch records the batch attributes (see batchAttributes) in the span
+// and the batch size of multi-row INSERT statements executed through the driver layer.
+//
+// Statements executed directly (eg. by gorm.DB.Exec) bypass the callbacks, leaving the driver layer the only place to record them.
+// Creates executed by the callbacks insert a single row, so they are never recorded twice.
+func (c *callbacks) recordBatch(ctx context.Context, span *trace.Span, query string, result driver.Result) {
+	if queryVerb(query) != "INSERT" || queryValueTuples(query) <= 1 {
+		return
+	}
+
+	// The number of rows is recorded as zero for drivers not supporting it
+	rows, err := result.RowsAffected()
+	if err != nil {
+		rows = 0
+	}
+
+	if span != nil {
+		span.AddAttributes(ocAttributes(batchAttributes(query, rows))...)
+	}
+
+	if !c.disableStats {
+		var table string
+		if tables := queryTables(query); len(tables) > 0 {
+			table = tables[0]
+		}
+
+		stats.Record(c.tagContext(ctx, OpCreate, table), c.measures.batchSize.M(batchSize(query, rows)))
+	}
+}
+
+type instrumentedDriver struct {
+	parent driver.Driver
+	c      *callbacks
+}
+
+func (d *instrumentedDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.parent.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &instrumentedConn{parent: conn, c: d.c}, nil
+}
+
+type instrumentedConn struct {
+	parent driver.Conn
+	c      *callbacks
+}
+
+func (c *instrumentedConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *instrumentedConn) PrepareContext(ctx context.Context, query string) (stmt driver.Stmt, err error) {
+	ctx, span := c.c.startDriverSpan(ctx, "sql:prepare", query)
+	defer func() { c.c.endDriverSpan(span, err) }()
+
+	if preparer, ok := c.parent.(driver.ConnPrepareContext); ok {
+		stmt, err = preparer.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.parent.Prepare(query)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &instrumentedStmt{parent: stmt, query: query, c: c.c}, nil
+}
+
+func (c *instrumentedConn) Close() error {
+	return c.parent.Close()
+}
+
+func (c *instrumentedConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *instrumentedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (tx driver.Tx, err error) {
+	_, span := c.c.startDriverSpan(ctx, "sql:begin", "")
+	defer func() { c.c.endDriverSpan(span, err) }()
+
+	if beginner, ok := c.parent.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+
+	return c.parent.Begin()
+}
+
+func (c *instrumentedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (result driver.Result, err error) {
+	execer, ok := c.parent.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	ctx, span := c.c.startDriverSpan(ctx, "sql:exec", query)
+	defer func() { c.c.endDriverSpan(span, err) }()
+
+	result, err = execer.ExecContext(ctx, query, args)
+	if err == nil {
+		c.c.recordBatch(ctx, span, query, result)
+	}
+
+	return result, err
+}
+
+func (c *instrumentedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (rows driver.Rows, err error) {
+	queryer, ok := c.parent.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	ctx, span := c.c.startDriverSpan(ctx, "sql:query", query)
+	defer func() { c.c.endDriverSpan(span, err) }()
+
+	return queryer.QueryContext(ctx, query, args)
+}
+
+func (c *instrumentedConn) Ping(ctx context.Context) (err error) {
+	pinger, ok := c.parent.(driver.Pinger)
+	if !ok {
+		return nil
+	}
+
+	ctx, span := c.c.startDriverSpan(ctx, "sql:ping", "")
+	defer func() { c.c.endDriverSpan(span, err) }()
+
+	return pinger.Ping(ctx)
+}
+
+func (c *instrumentedConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := c.parent.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+
+	return driver.ErrSkip
+}
+
+func (c *instrumentedConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.parent.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+
+	return nil
+}
+
+type instrumentedStmt struct {
+	parent driver.Stmt
+	query  string
+	c      *callbacks
+}
+
+func (s *instrumentedStmt) Close() error {
+	return s.parent.Close()
+}
+
+func (s *instrumentedStmt) NumInput() int {
+	return s.parent.NumInput()
+}
+
+func (s *instrumentedStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.parent.Exec(args)
+}
+
+func (s *instrumentedStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.parent.Query(args)
+}
+
+func (s *instrumentedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (result driver.Result, err error) {
+	ctx, span := s.c.startDriverSpan(ctx, "sql:exec", s.query)
+	defer func() { s.c.endDriverSpan(span, err) }()
+
+	if execer, ok := s.parent.(driver.StmtExecContext); ok {
+		result, err = execer.ExecContext(ctx, args)
+	} else {
+		var values []driver.Value
+
+		values, err = namedValuesToValues(args)
+		if err != nil {
+			return nil, err
+		}
+
+		result, err = s.parent.Exec(values)
+	}
+
+	if err == nil {
+		s.c.recordBatch(ctx, span, s.query, result)
+	}
+
+	return result, err
+}
+
+func (s *instrumentedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (rows driver.Rows, err error) {
+	ctx, span := s.c.startDriverSpan(ctx, "sql:query", s.query)
+	defer func() { s.c.endDriverSpan(span, err) }()
+
+	if queryer, ok := s.parent.(driver.StmtQueryContext); ok {
+		return queryer.QueryContext(ctx, args)
+	}
+
+	values, err := namedValuesToValues(args)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.parent.Query(values)
+}
+
+func (s *instrumentedStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := s.parent.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+
+	return driver.ErrSkip
+}
+
+// namedValuesToValues converts named values for drivers not supporting them.
+func namedValuesToValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, errNamedParameters
+		}
+
+		values[i] = arg.Value
+	}
+
+	return values, nil
+}
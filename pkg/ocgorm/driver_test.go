@@ -0,0 +1,119 @@
+package ocgorm_test
+
+import (
+	"context"
+	"testing"
+
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"go.opencensus.io/trace"
+
+	"github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgorm"
+	"github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgorm/ocgormtest"
+)
+
+func TestOpen(t *testing.T) {
+	exporter, unregister := ocgormtest.NewExporter()
+	defer unregister()
+
+	for _, name := range []string{"primary", "replica"} {
+		db, err := ocgorm.Open("sqlite3", ":memory:", ocgorm.DefaultAttributes{trace.StringAttribute("db", name)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ctx, span := trace.StartSpan(context.Background(), "parent")
+
+		if err := ocgorm.Ping(ctx, db); err != nil {
+			t.Fatal(err)
+		}
+
+		span.End()
+
+		_ = db.Close()
+
+		ping := exporter.SpansWithAttribute("db", name)
+
+		var driverSpans int
+
+		for _, s := range ping {
+			if s.Name == "sql:ping" {
+				driverSpans++
+			}
+		}
+
+		// Each database has its own driver options
+		if driverSpans != 1 {
+			t.Errorf("expected one driver span of the %s database, got %d", name, driverSpans)
+		}
+	}
+
+	for _, s := range exporter.SpansWithName("sql:ping") {
+		var parent *trace.SpanData
+
+		for _, p := range exporter.SpansWithName("gorm:ping") {
+			if p.SpanID == s.ParentSpanID {
+				parent = p
+			}
+		}
+
+		if parent == nil {
+			t.Error("the driver span is not a child of the ping span")
+		}
+	}
+}
+
+func TestOpen_ExecBatch(t *testing.T) {
+	db, err := ocgorm.Open("sqlite3", ":memory:", ocgorm.AllowRoot(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// Every connection has its own in-memory database
+	db.DB().SetMaxOpenConns(1)
+
+	if err := db.AutoMigrate(&Person{}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	defer registerViews(t, ocgorm.SQLClientBatchSizeView)()
+
+	exporter, unregister := ocgormtest.NewExporter()
+	defer unregister()
+
+	// Exec bypasses the callbacks
+	err = db.Exec(
+		"INSERT INTO people (first_name, last_name) VALUES (?, ?), (?, ?), (?, ?)",
+		"John", "Doe", "Jane", "Doe", "Jack", "Doe",
+	).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spans := exporter.SpansWithAttribute(ocgorm.BatchTuplesAttribute, int64(3))
+	if len(spans) != 1 {
+		t.Fatalf("expected one span with the batch attributes, got %d", len(spans))
+	}
+
+	if spans[0].Name != "sql:exec" {
+		t.Errorf("expected the batch attributes in the sql:exec span, got %s", spans[0].Name)
+	}
+
+	if actual := spans[0].Attributes[ocgorm.BatchRowsAttribute]; actual != int64(3) {
+		t.Errorf("expected three inserted rows, got %v", actual)
+	}
+
+	rows, err := ocgormtest.ViewRows(ocgorm.SQLClientBatchSizeView, tag.Tag{Key: ocgorm.Table, Value: "people"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) != 1 {
+		t.Fatalf("expected one row, got %d", len(rows))
+	}
+
+	if data, ok := rows[0].Data.(*view.DistributionData); !ok || data.Count != 1 || data.Max != 3 {
+		t.Errorf("expected one batch of three rows, got %v", rows[0].Data)
+	}
+}
@@ -0,0 +1,55 @@
+package ocgorm_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"go.opencensus.io/plugin/ochttp"
+
+	"github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgorm"
+	"github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgorm/ocgormtest"
+)
+
+func ExampleMiddleware() {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		panic(err)
+	}
+	defer db.Close()
+
+	ocgorm.RegisterCallbacks(db)
+
+	db.AutoMigrate(&Person{})
+
+	exporter, unregister := ocgormtest.NewExporter()
+	defer unregister()
+
+	gin.SetMode(gin.ReleaseMode)
+
+	r := gin.New()
+	r.Use(ocgorm.Middleware(db))
+	r.GET("/people", func(c *gin.Context) {
+		var people []Person
+
+		// No need to bind the request context manually
+		ocgorm.FromGinContext(c).Find(&people)
+
+		c.JSON(http.StatusOK, people)
+	})
+
+	handler := &ochttp.Handler{Handler: r}
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/people", nil))
+
+	server := exporter.SpansWithName("/people")[0]
+	query := exporter.SpansWithName("gorm:query")[0]
+
+	fmt.Println("same trace:", query.TraceID == server.TraceID)
+	fmt.Println("parented by the server span:", query.ParentSpanID == server.SpanID)
+
+	// Output:
+	// same trace: true
+	// parented by the server span: true
+}
@@ -0,0 +1,61 @@
+package ocgorm
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/tag"
+)
+
+// ginContextKey is the key under which Middleware stores the db instance in the gin context.
+const ginContextKey = "_opencensusGormDB"
+
+// Middleware returns a gin middleware binding the request context to the db instance (see WithContext).
+//
+// The context bound instance can be retrieved with FromGinContext.
+// The number of operations executed by the request is recorded when the request is handled (see WithRequestStats).
+//
+// The server span of the request can be instrumented by ocgin.Middleware.
+func Middleware(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := WithRequestStats(c.Request.Context())
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Set(ginContextKey, WithContext(ctx, db))
+
+		c.Next()
+
+		FlushRequestStats(c.Request.Context())
+	}
+}
+
+// Route returns a gin middleware setting the route of the request (see ochttp.SetRoute).
+//
+// Unlike ochttp.SetRoute, it also adds the route to the tags of the request context,
+// so that the stats of the database calls executed with the context can be broken down by route (see RouteViews).
+// The db instance bound by Middleware is bound to the new context.
+func Route(route string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ochttp.SetRoute(c.Request.Context(), route)
+
+		ctx, err := tag.New(c.Request.Context(), tag.Upsert(ochttp.KeyServerRoute, route))
+		if err != nil {
+			return
+		}
+
+		c.Request = c.Request.WithContext(ctx)
+
+		if db := FromGinContext(c); db != nil {
+			c.Set(ginContextKey, WithContext(ctx, db))
+		}
+	}
+}
+
+// FromGinContext returns the db instance bound to the request context by Middleware.
+// It returns nil if Middleware is not registered.
+func FromGinContext(c *gin.Context) *gorm.DB {
+	rdb, _ := c.Get(ginContextKey)
+	db, _ := rdb.(*gorm.DB)
+
+	return db
+}
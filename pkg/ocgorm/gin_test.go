@@ -0,0 +1,87 @@
+package ocgorm_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/tag"
+	"go.opencensus.io/trace"
+
+	"github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgorm"
+	"github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgorm/ocgormtest"
+)
+
+func TestRoute(t *testing.T) {
+	defer registerViews(t, ocgorm.RouteViews...)()
+
+	gin.SetMode(gin.TestMode)
+
+	db := newTestDB(t)
+
+	exporter, unregister := ocgormtest.NewExporter()
+	defer unregister()
+
+	engine := gin.New()
+	engine.Use(ocgorm.Middleware(db))
+	engine.GET("/people/:id", ocgorm.Route("/people/:id"), func(c *gin.Context) {
+		var people []Person
+
+		if err := ocgorm.FromGinContext(c).Where("id = ?", c.Param("id")).Find(&people).Error; err != nil {
+			c.AbortWithStatus(http.StatusInternalServerError)
+
+			return
+		}
+
+		c.JSON(http.StatusOK, people)
+	})
+
+	handler := &ochttp.Handler{Handler: engine}
+
+	recorder := httptest.NewRecorder()
+
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/people/1", nil))
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, recorder.Code)
+	}
+
+	spans := exporter.SpansWithName("gorm:query")
+	if len(spans) != 1 {
+		t.Fatalf("expected one query span, got %d", len(spans))
+	}
+
+	var server *trace.SpanData
+
+	for _, span := range exporter.Spans() {
+		if span.SpanKind == trace.SpanKindServer {
+			server = span
+		}
+	}
+
+	if server == nil || spans[0].ParentSpanID != server.SpanID {
+		t.Error("the query span is not a child of the server span")
+	}
+
+	if actual := spans[0].Attributes[ocgorm.HTTPRouteAttribute]; actual != "/people/:id" {
+		t.Errorf("expected the route attribute, got %v", actual)
+	}
+
+	for _, v := range ocgorm.RouteViews {
+		rows, err := ocgormtest.ViewRows(
+			v,
+			tag.Tag{Key: ochttp.KeyServerRoute, Value: "/people/:id"},
+			tag.Tag{Key: ocgorm.Operation, Value: ocgorm.OpQuery},
+			tag.Tag{Key: ocgorm.Table, Value: "people"},
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(rows) != 1 {
+			t.Errorf("expected one row of %s with the route, got %d", v.Name, len(rows))
+		}
+	}
+}
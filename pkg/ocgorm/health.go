@@ -0,0 +1,49 @@
+package ocgorm
+
+import (
+	"context"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Ping pings the database using the given context.
+//
+// The ping is recorded as a gorm:ping span and as a ping operation in stats,
+// following the configuration of the callbacks registered in db.
+//
+// An error is returned if the db instance has no connection pool (eg. it is a transaction).
+func Ping(ctx context.Context, db *gorm.DB) error {
+	sqlDB := db.DB()
+	if sqlDB == nil {
+		return errNoConnectionPool
+	}
+
+	ctx, end := callbacksFromDB(db).startSpan(ctx, "ping", "", "")
+
+	err := sqlDB.PingContext(ctx)
+
+	end(err)
+
+	return err
+}
+
+// DBCheck returns a health check (see ocgin.HealthHandler) that pings the database using the given context (see Ping).
+func DBCheck(db *gorm.DB) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		return Ping(ctx, db)
+	}
+}
+
+// healthCheckContextKey marks the contexts of health checks.
+type healthCheckContextKey struct{}
+
+// WithHealthCheck marks the context as belonging to a health check (see ocgin.HealthHandler):
+// operations executed with it are neither traced nor recorded in stats, keeping probe traffic out of the telemetry.
+func WithHealthCheck(ctx context.Context) context.Context {
+	return context.WithValue(ctx, healthCheckContextKey{}, true)
+}
+
+// isHealthCheck reports whether the context belongs to a health check (see WithHealthCheck).
+func isHealthCheck(ctx context.Context) bool {
+	return ctx.Value(healthCheckContextKey{}) != nil
+}
@@ -0,0 +1,96 @@
+package ocgorm_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"go.opencensus.io/stats/view"
+
+	"github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgorm"
+	"github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgorm/ocgormtest"
+)
+
+// newIdleDB returns a healthy database without open connections.
+func newIdleDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Connections are closed as soon as they are released
+	db.DB().SetMaxIdleConns(0)
+
+	if open := db.DB().Stats().OpenConnections; open != 0 {
+		t.Fatalf("expected no open connections, got %d", open)
+	}
+
+	return db
+}
+
+func TestPing_NoOpenConnections(t *testing.T) {
+	db := newIdleDB(t)
+	defer db.Close()
+
+	if err := ocgorm.Ping(context.Background(), db); err != nil {
+		t.Errorf("expected a healthy database, got %v", err)
+	}
+
+	if err := ocgorm.DBCheck(db)(context.Background()); err != nil {
+		t.Errorf("expected the health check to pass, got %v", err)
+	}
+}
+
+func TestRecordStats_NoOpenConnections(t *testing.T) {
+	defer registerViews(t, ocgorm.SQLClientOpenConnectionsView, ocgorm.SQLClientDBUpView)()
+
+	db := newIdleDB(t)
+	defer db.Close()
+
+	stop, err := ocgorm.RecordStats(db, time.Hour, ocgorm.DatabaseName("idle"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+
+	open, err := ocgormtest.ViewRows(ocgorm.SQLClientOpenConnectionsView)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(open) != 1 || open[0].Data.(*view.LastValueData).Value != 0 {
+		t.Errorf("expected zero open connections to be recorded, got %v", open)
+	}
+
+	// The database is pinged right after recording starts
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		up, err := ocgormtest.ViewRows(ocgorm.SQLClientDBUpView)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(up) == 1 {
+			if value := up[0].Data.(*view.LastValueData).Value; value != 1 {
+				t.Errorf("expected the database to be up, got %f", value)
+			}
+
+			return
+		}
+	}
+
+	t.Error("the result of the ping is not recorded")
+}
+
+func TestPing_NoConnectionPool(t *testing.T) {
+	db := newTestDB(t)
+
+	tx := db.Begin()
+	defer tx.Rollback()
+
+	if err := ocgorm.Ping(context.Background(), tx); err == nil {
+		t.Error("expected an error for a db instance without a connection pool")
+	}
+}
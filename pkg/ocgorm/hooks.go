@@ -0,0 +1,154 @@
+package ocgorm
+
+import (
+	"context"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"go.opencensus.io/trace"
+)
+
+// Gorm scope keys
+var (
+	hookStartScopeKey  = "_opencensusHookStart"
+	hookSpanScopeKey   = "_opencensusHookSpan"
+	hookParentScopeKey = "_opencensusHookParent"
+)
+
+// TraceModelHooks records the start and the duration of the model hooks (eg. BeforeSave, AfterFind)
+// as annotations of the span of the operation.
+//
+// By default the hooks run outside of the span of the operation (see WrapCallbacks),
+// so they are recorded in the parent span.
+// Hooks are only recorded in OpenCensus spans (see WithTracer).
+type TraceModelHooks bool
+
+func (t TraceModelHooks) apply(c *callbacks) {
+	c.traceModelHooks = bool(t)
+}
+
+// ModelHookSpans records model hooks as child spans instead of annotations (requires TraceModelHooks).
+type ModelHookSpans bool
+
+func (m ModelHookSpans) apply(c *callbacks) {
+	c.modelHookSpans = bool(m)
+}
+
+// modelHooks lists the gorm callbacks invoking model hooks and the hooks invoked by them.
+var modelHooks = map[string][]struct {
+	callback string
+	hooks    string
+}{
+	OpCreate: {
+		{"gorm:before_create", "BeforeSave/BeforeCreate"},
+		{"gorm:after_create", "AfterCreate/AfterSave"},
+	},
+	OpQuery: {
+		{"gorm:after_query", "AfterFind"},
+	},
+	OpUpdate: {
+		{"gorm:before_update", "BeforeSave/BeforeUpdate"},
+		{"gorm:after_update", "AfterUpdate/AfterSave"},
+	},
+	OpDelete: {
+		{"gorm:before_delete", "BeforeDelete"},
+		{"gorm:after_delete", "AfterDelete"},
+	},
+}
+
+// registerHookCallbacks registers callbacks around the gorm callbacks invoking model hooks of an operation.
+func (c *callbacks) registerHookCallbacks(processor func() *gorm.CallbackProcessor, operation string) {
+	for _, h := range modelHooks[operation] {
+		hooks := h.hooks
+
+		processor().Before(h.callback).Register("instrumentation:start_"+h.callback[len("gorm:"):]+"_hooks", func(scope *gorm.Scope) {
+			c.startHooks(scope, hooks)
+		})
+		processor().After(h.callback).Register("instrumentation:end_"+h.callback[len("gorm:"):]+"_hooks", func(scope *gorm.Scope) {
+			c.endHooks(scope, hooks)
+		})
+	}
+}
+
+// hookSpan returns the span model hooks are recorded in:
+// the span of the operation or its parent span if the hooks run before or after it.
+func hookSpan(scope *gorm.Scope) (context.Context, *trace.Span) {
+	rctx, _ := scope.Get(contextScopeKey)
+	ctx, ok := rctx.(context.Context)
+	if !ok || ctx == nil {
+		ctx = context.Background()
+	}
+
+	rspan, _ := scope.InstanceGet(spanScopeKey)
+	if span, ok := rspan.(ocSpan); ok && span.Span != nil {
+		return trace.NewContext(ctx, span.Span), span.Span
+	}
+
+	// The span of the operation has already ended
+	if rparent, ok := scope.InstanceGet(hookParentScopeKey); ok {
+		parent, _ := rparent.(*trace.Span)
+		if parent == nil {
+			return ctx, nil
+		}
+
+		return trace.NewContext(ctx, parent), parent
+	}
+
+	return ctx, trace.FromContext(ctx)
+}
+
+func (c *callbacks) startHooks(scope *gorm.Scope, hooks string) {
+	if skip, _ := scope.Get(skipScopeKey); skip == true || c.disableTrace {
+		return
+	}
+
+	ctx, span := hookSpan(scope)
+	if span == nil {
+		return
+	}
+
+	if c.modelHookSpans {
+		_, span = trace.StartSpan(ctx, "gorm:hooks "+hooks, trace.WithSampler(c.spanSampler()))
+		span.AddAttributes(c.defaultAttributes...)
+
+		scope.InstanceSet(hookSpanScopeKey, span)
+
+		return
+	}
+
+	span.Annotate([]trace.Attribute{trace.StringAttribute("hooks", hooks)}, "gorm: hooks started")
+
+	scope.InstanceSet(hookStartScopeKey, time.Now())
+}
+
+func (c *callbacks) endHooks(scope *gorm.Scope, hooks string) {
+	rspan, _ := scope.InstanceGet(hookSpanScopeKey)
+	if span, ok := rspan.(*trace.Span); ok && span != nil {
+		scope.InstanceSet(hookSpanScopeKey, nil)
+
+		span.End()
+
+		return
+	}
+
+	rstart, _ := scope.InstanceGet(hookStartScopeKey)
+	start, ok := rstart.(time.Time)
+	if !ok {
+		return
+	}
+
+	scope.InstanceSet(hookStartScopeKey, nil)
+
+	_, span := hookSpan(scope)
+	if span == nil {
+		return
+	}
+
+	span.Annotate(
+		[]trace.Attribute{
+			trace.StringAttribute("hooks", hooks),
+			trace.StringAttribute("duration", time.Since(start).String()),
+		},
+		"gorm: hooks finished",
+	)
+}
@@ -0,0 +1,103 @@
+package ocgorm
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"go.opencensus.io/stats/view"
+)
+
+// defaultStatsInterval is the default connection pool stats recording interval of Instrument.
+const defaultStatsInterval = 10 * time.Second
+
+// StatsInterval sets the interval of recording connection pool stats in Instrument.
+// Zero disables recording connection pool stats. Defaults to 10 seconds.
+type StatsInterval time.Duration
+
+func (s StatsInterval) apply(c *callbacks) {
+	c.statsInterval = time.Duration(s)
+}
+
+// Instrument sets up the instrumentation of a database instance in one call:
+// it registers the callbacks, registers the views (respecting the Namespace and StatementTag options)
+// and starts recording connection pool stats (see StatsInterval and RecordStats).
+//
+// Nothing is set up if an error is returned (eg. a conflicting view is already registered):
+// the callbacks are only registered once everything else succeeded.
+//
+// The returned function stops recording connection pool stats.
+func Instrument(db *gorm.DB, opts ...Option) (func(), error) {
+	c := newCallbacks(append([]Option{StatsInterval(defaultStatsInterval)}, opts...)...)
+
+	stop := func() {}
+
+	if !c.disableStats {
+		recordStats := c.statsInterval > 0
+
+		if recordStats && db.DB() == nil {
+			return nil, errNoConnectionPool
+		}
+
+		registered, err := registerViews(c.views())
+		if err != nil {
+			return nil, err
+		}
+
+		if recordStats {
+			stop, err = RecordStats(db, c.statsInterval, DatabaseName(c.databaseName))
+			if err != nil {
+				view.Unregister(registered...)
+
+				return nil, err
+			}
+		}
+	}
+
+	RegisterCallbacks(db, opts...)
+
+	return stop, nil
+}
+
+// registerViews registers views and returns the ones that were not registered before.
+// If any of the views fails to register, the ones registered by the call are unregistered.
+func registerViews(views []*view.View) ([]*view.View, error) {
+	var registered []*view.View
+
+	for _, v := range views {
+		if view.Find(v.Name) == nil {
+			registered = append(registered, v)
+		}
+	}
+
+	if err := view.Register(views...); err != nil {
+		view.Unregister(registered...)
+
+		return nil, err
+	}
+
+	return registered, nil
+}
+
+// views returns the views recording the stats of the configuration.
+func (c *callbacks) views() []*view.View {
+	if c.namespace == "" {
+		views := append([]*view.View{}, DefaultViews...)
+
+		if c.statementTag {
+			views = append(views, StatementViews...)
+		}
+
+		return views
+	}
+
+	views := append([]*view.View{}, QueryViews...)
+	views = append(views, TransactionViews...)
+
+	if c.statementTag {
+		views = append(views, StatementViews...)
+	}
+
+	views = namespacedViews(views, Namespace(c.namespace))
+
+	return append(append(views, RequestViews...), ConnectionViews...)
+}
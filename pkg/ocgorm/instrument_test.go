@@ -0,0 +1,73 @@
+package ocgorm_test
+
+import (
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+
+	"github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgorm"
+	"github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgorm/ocgormtest"
+)
+
+func TestInstrument_Error(t *testing.T) {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if err := db.AutoMigrate(&Person{}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// A different view registered under the name of one of the default views
+	conflicting := &view.View{
+		Name:        ocgorm.SQLClientLatencyView.Name,
+		Measure:     stats.Int64("ocgorm_test/conflicting", "Conflicting measure", stats.UnitDimensionless),
+		Aggregation: view.Count(),
+	}
+
+	defer registerViews(t, conflicting)()
+
+	if _, err := ocgorm.Instrument(db, ocgorm.AllowRoot(true)); err == nil {
+		t.Fatal("expected an error for a conflicting view")
+	}
+
+	if v := view.Find(ocgorm.SQLClientCallsView.Name); v != nil {
+		t.Error("the views registered before the error are expected to be unregistered")
+	}
+
+	exporter, unregister := ocgormtest.NewExporter()
+	defer unregister()
+
+	var people []Person
+
+	if err := db.Find(&people).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if spans := exporter.Spans(); len(spans) != 0 {
+		t.Errorf("expected the callbacks not to be registered, got %d spans", len(spans))
+	}
+}
+
+func TestInstrument_NoConnectionPool(t *testing.T) {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	tx := db.Begin()
+	defer tx.Rollback()
+
+	if _, err := ocgorm.Instrument(tx); err == nil {
+		t.Error("expected an error for a db instance without a connection pool")
+	}
+
+	if v := view.Find(ocgorm.SQLClientCallsView.Name); v != nil {
+		t.Error("expected the views not to be registered")
+	}
+}
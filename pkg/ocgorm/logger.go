@@ -0,0 +1,112 @@
+package ocgorm
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// gormLogger is the logger interface accepted by gorm.DB.SetLogger.
+type gormLogger interface {
+	Print(v ...interface{})
+}
+
+// Logger is a gorm logger attaching log entries to the span of the logged operation as annotations.
+//
+// gorm does not pass the operation to its logger, so the callbacks need to know about the logger:
+// Logger is an Option binding itself to the span of each operation.
+// Passing it to RegisterCallbacks is all the wiring needed, as it also sets the logger of the db instance
+// (setting it using gorm.DB.SetLogger only is not enough).
+//
+//	db.LogMode(true)
+//
+//	ocgorm.RegisterCallbacks(db, ocgorm.NewLogger(nil))
+//
+// Queries are recorded according to the Query, QueryOnError, ObfuscateQuery and MaxQueryLength options.
+// Note that gorm only logs queries in detailed log mode (see gorm.DB.LogMode).
+type Logger struct {
+	logger gormLogger
+
+	c     *callbacks
+	scope *gorm.Scope
+	span  Span
+}
+
+// NewLogger returns a new Logger.
+// Log entries are also passed to logger (eg. gorm.Logger) unless it is nil.
+func NewLogger(logger gormLogger) *Logger {
+	return &Logger{
+		logger: logger,
+	}
+}
+
+func (l *Logger) apply(c *callbacks) {
+	c.logger = l
+}
+
+// bind returns a copy of the logger attaching log entries of the operation of the scope to span.
+func (l *Logger) bind(c *callbacks, scope *gorm.Scope, span Span) *Logger {
+	return &Logger{
+		logger: l.logger,
+		c:      c,
+		scope:  scope,
+		span:   span,
+	}
+}
+
+// Print implements the gorm logger interface.
+func (l *Logger) Print(v ...interface{}) {
+	if l.logger != nil {
+		l.logger.Print(v...)
+	}
+
+	if l.span == nil || len(v) < 2 {
+		return
+	}
+
+	attributes := []Attribute{
+		StringAttribute("source", fmt.Sprint(v[1])),
+	}
+
+	// gorm logs queries as: "sql", source, duration, sql, vars, rows affected
+	if v[0] == "sql" && len(v) >= 6 {
+		duration, _ := v[2].(time.Duration)
+		rowsAffected, _ := v[5].(int64)
+
+		attributes = append(
+			attributes,
+			StringAttribute("duration", duration.String()),
+			Int64Attribute("rows_affected", rowsAffected),
+		)
+
+		// gorm logs queries after executing them, so errors are already known
+		if l.c.recordsQuery(l.scope) {
+			attributes = append(attributes, StringAttribute("query", l.c.processQuery(fmt.Sprint(v[3]))))
+		}
+
+		if l.c.slowQueryThreshold > 0 && duration > l.c.slowQueryThreshold {
+			l.span.Annotate(attributes, "gorm: slow query")
+
+			return
+		}
+
+		l.span.Annotate(attributes, "gorm: sql")
+
+		return
+	}
+
+	// gorm logs other messages (eg. errors) as: "log", source, values...
+	if v[0] == "log" && len(v) > 2 {
+		l.span.Annotate(attributes, fmt.Sprint(v[2:]...))
+	}
+}
+
+// bindLogger makes the logger of the operation attach log entries to span.
+func (c *callbacks) bindLogger(scope *gorm.Scope, span Span) {
+	if c.logger == nil {
+		return
+	}
+
+	scope.DB().SetLogger(c.logger.bind(c, scope, span))
+}
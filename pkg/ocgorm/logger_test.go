@@ -0,0 +1,66 @@
+package ocgorm_test
+
+import (
+	"context"
+	"testing"
+
+	"go.opencensus.io/trace"
+
+	"github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgorm"
+	"github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgorm/ocgormtest"
+)
+
+// sqlAnnotations returns the annotations of the logged queries.
+func sqlAnnotations(span *trace.SpanData) []trace.Annotation {
+	var annotations []trace.Annotation
+
+	for _, annotation := range span.Annotations {
+		if annotation.Message == "gorm: sql" {
+			annotations = append(annotations, annotation)
+		}
+	}
+
+	return annotations
+}
+
+func TestLogger(t *testing.T) {
+	db := newTestDB(t, ocgorm.NewLogger(nil), ocgorm.QueryOnError(true))
+	db.LogMode(true)
+
+	exporter, unregister := ocgormtest.NewExporter()
+	defer unregister()
+
+	ctx, span := trace.StartSpan(context.Background(), "parent")
+
+	if err := ocgorm.WithContext(ctx, db).Create(&Person{FirstName: "John"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var people []Person
+
+	if err := ocgorm.WithContext(ctx, db).Table("unknown").Find(&people).Error; err == nil {
+		t.Fatal("expected an error")
+	}
+
+	span.End()
+
+	create := findSpan(t, exporter, "gorm:create", "people")
+
+	annotations := sqlAnnotations(create)
+	if len(annotations) != 1 {
+		t.Fatalf("expected the query to be logged in the span, got %d annotations", len(annotations))
+	}
+
+	if _, ok := annotations[0].Attributes["query"]; ok {
+		t.Error("queries of successful operations must not be recorded")
+	}
+
+	failed := sqlAnnotations(findSpan(t, exporter, "gorm:query", "unknown"))
+	if len(failed) != 1 {
+		t.Fatalf("expected the failed query to be logged in its span, got %d annotations", len(failed))
+	}
+
+	if query := failed[0].Attributes["query"]; query != `SELECT * FROM "unknown"  ` {
+		t.Errorf("expected the query of the failed operation to be recorded, got %v", query)
+	}
+}
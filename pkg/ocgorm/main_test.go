@@ -0,0 +1,75 @@
+package ocgorm_test
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/trace"
+
+	"github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgorm"
+	"github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgorm/ocgormtest"
+)
+
+func TestMain(m *testing.M) {
+	trace.ApplyConfig(trace.Config{DefaultSampler: trace.AlwaysSample()})
+
+	os.Exit(m.Run())
+}
+
+type Person struct {
+	ID        uint
+	FirstName string
+	LastName  string
+}
+
+type Author struct {
+	ID    uint
+	Name  string
+	Books []Book
+}
+
+type Book struct {
+	ID       uint
+	AuthorID uint
+	Title    string
+}
+
+// newTestDB returns an in-memory sqlite database with the callbacks registered and the test tables migrated.
+func newTestDB(t testing.TB, opts ...ocgorm.Option) *gorm.DB {
+	t.Helper()
+
+	sqlDB, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every connection has its own in-memory database
+	sqlDB.SetMaxOpenConns(1)
+
+	db, err := ocgormtest.NewMockDB("sqlite3", sqlDB, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.AutoMigrate(&Person{}, &Author{}, &Book{}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+// registerViews registers views for the duration of a test.
+// Unregistering the views (by calling the returned function) drops the recorded data.
+func registerViews(t testing.TB, views ...*view.View) func() {
+	t.Helper()
+
+	if err := view.Register(views...); err != nil {
+		t.Fatal(err)
+	}
+
+	return func() { view.Unregister(views...) }
+}
@@ -0,0 +1,70 @@
+package ocgorm
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jinzhu/gorm"
+	"go.opencensus.io/trace"
+)
+
+// AutoMigrate runs gorm's auto migration for each model (see gorm.DB.AutoMigrate).
+//
+// A gorm:migrate span is started (following the AllowRoot configuration of the callbacks)
+// with a child span for the migration of each model, so slow migrations can be identified.
+// Migration stops at the first failing model.
+func AutoMigrate(ctx context.Context, db *gorm.DB, models ...interface{}) error {
+	c := callbacksFromDB(db)
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	var span *trace.Span
+
+	if !c.disableTrace && (trace.FromContext(ctx) != nil || c.allowRoot) {
+		ctx, span = trace.StartSpan(ctx, "gorm:migrate", trace.WithSpanKind(c.spanKind), trace.WithSampler(c.spanSampler()))
+		defer span.End()
+
+		span.AddAttributes(c.defaultAttributes...)
+	}
+
+	for _, model := range models {
+		if err := c.migrate(ctx, db, model, span != nil); err != nil {
+			if span != nil {
+				span.SetStatus(c.statusFromError(err))
+			}
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+// migrate runs the auto migration of a single model, optionally in a child span.
+func (c *callbacks) migrate(ctx context.Context, db *gorm.DB, model interface{}, traced bool) error {
+	table := db.NewScope(model).TableName()
+
+	if !traced {
+		return WithContext(ctx, db).AutoMigrate(model).Error
+	}
+
+	ctx, span := trace.StartSpan(
+		ctx,
+		fmt.Sprintf("gorm:migrate %s", table),
+		trace.WithSpanKind(c.spanKind),
+		trace.WithSampler(c.spanSampler()),
+	)
+	defer span.End()
+
+	span.AddAttributes(c.defaultAttributes...)
+	span.AddAttributes(trace.StringAttribute(TableAttribute, table))
+
+	err := WithContext(ctx, db).AutoMigrate(model).Error
+	if err != nil {
+		span.SetStatus(c.statusFromError(err))
+	}
+
+	return err
+}
@@ -0,0 +1,124 @@
+package ocgorm
+
+import (
+	"strings"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+)
+
+// defaultNamespace is the prefix of the names of the default measures and views.
+const defaultNamespace = "go.sql"
+
+// Namespace records query and transaction stats into measures named <namespace>/client/...
+// instead of go.sql/client/... (eg. to avoid collisions with other database/sql instrumentations).
+//
+// The views of the namespace are returned by NewViews.
+type Namespace string
+
+func (n Namespace) apply(c *callbacks) {
+	c.namespace = string(n)
+	c.measures = newMeasures(string(n))
+}
+
+// measures contains the measures stats are recorded into.
+type measures struct {
+	queryCount       *stats.Int64Measure
+	latencyMs        *stats.Float64Measure
+	errorCount       *stats.Int64Measure
+	rowsAffected     *stats.Int64Measure
+	slowQueryCount   *stats.Int64Measure
+	rowsReturned     *stats.Int64Measure
+	batchSize        *stats.Int64Measure
+	notFoundCount    *stats.Int64Measure
+	uninstrumented   *stats.Int64Measure
+	txLatencyMs      *stats.Float64Measure
+	txCommitCount    *stats.Int64Measure
+	txRollbackCount  *stats.Int64Measure
+	txStatementCount *stats.Int64Measure
+	txRetryCount     *stats.Int64Measure
+	txLongCount      *stats.Int64Measure
+}
+
+// defaultMeasures contains the measures of the default namespace.
+var defaultMeasures = &measures{
+	queryCount:       MeasureQueryCount,
+	latencyMs:        MeasureLatencyMs,
+	errorCount:       MeasureErrorCount,
+	rowsAffected:     MeasureRowsAffected,
+	slowQueryCount:   MeasureSlowQueryCount,
+	rowsReturned:     MeasureRowsReturned,
+	batchSize:        MeasureBatchSize,
+	notFoundCount:    MeasureNotFoundCount,
+	uninstrumented:   MeasureUninstrumentedCount,
+	txLatencyMs:      MeasureTransactionLatencyMs,
+	txCommitCount:    MeasureTransactionCommitCount,
+	txRollbackCount:  MeasureTransactionRollbackCount,
+	txStatementCount: MeasureTransactionStatementCount,
+	txRetryCount:     MeasureTransactionRetryCount,
+	txLongCount:      MeasureTransactionLongCount,
+}
+
+// newMeasures returns the measures of a namespace.
+func newMeasures(namespace string) *measures {
+	return &measures{
+		queryCount:       namespacedInt64(MeasureQueryCount, namespace),
+		latencyMs:        namespacedFloat64(MeasureLatencyMs, namespace),
+		errorCount:       namespacedInt64(MeasureErrorCount, namespace),
+		rowsAffected:     namespacedInt64(MeasureRowsAffected, namespace),
+		slowQueryCount:   namespacedInt64(MeasureSlowQueryCount, namespace),
+		rowsReturned:     namespacedInt64(MeasureRowsReturned, namespace),
+		batchSize:        namespacedInt64(MeasureBatchSize, namespace),
+		notFoundCount:    namespacedInt64(MeasureNotFoundCount, namespace),
+		uninstrumented:   namespacedInt64(MeasureUninstrumentedCount, namespace),
+		txLatencyMs:      namespacedFloat64(MeasureTransactionLatencyMs, namespace),
+		txCommitCount:    namespacedInt64(MeasureTransactionCommitCount, namespace),
+		txRollbackCount:  namespacedInt64(MeasureTransactionRollbackCount, namespace),
+		txStatementCount: namespacedInt64(MeasureTransactionStatementCount, namespace),
+		txRetryCount:     namespacedInt64(MeasureTransactionRetryCount, namespace),
+		txLongCount:      namespacedInt64(MeasureTransactionLongCount, namespace),
+	}
+}
+
+// NewViews returns the query, transaction, statement and route views of a namespace (see Namespace).
+func NewViews(namespace Namespace) []*view.View {
+	views := append(append(append([]*view.View{}, QueryViews...), TransactionViews...), StatementViews...)
+	views = append(views, RouteViews...)
+
+	return namespacedViews(views, namespace)
+}
+
+// namespacedViews returns copies of the views recording the measures of a namespace.
+func namespacedViews(views []*view.View, namespace Namespace) []*view.View {
+	result := make([]*view.View, 0, len(views))
+
+	for _, v := range views {
+		vc := *v
+		vc.Name = namespacedName(v.Name, string(namespace))
+
+		switch m := v.Measure.(type) {
+		case *stats.Int64Measure:
+			vc.Measure = namespacedInt64(m, string(namespace))
+
+		case *stats.Float64Measure:
+			vc.Measure = namespacedFloat64(m, string(namespace))
+		}
+
+		result = append(result, &vc)
+	}
+
+	return result
+}
+
+// namespacedName replaces the default namespace in a measure or view name.
+func namespacedName(name string, namespace string) string {
+	return namespace + strings.TrimPrefix(name, defaultNamespace)
+}
+
+func namespacedInt64(m *stats.Int64Measure, namespace string) *stats.Int64Measure {
+	return stats.Int64(namespacedName(m.Name(), namespace), m.Description(), m.Unit())
+}
+
+func namespacedFloat64(m *stats.Float64Measure, namespace string) *stats.Float64Measure {
+	return stats.Float64(namespacedName(m.Name(), namespace), m.Description(), m.Unit())
+}
@@ -0,0 +1,221 @@
+package ocgorm
+
+import (
+	"regexp"
+	"strings"
+)
+
+// inListRegexp matches IN lists containing placeholders only.
+var inListRegexp = regexp.MustCompile(`(?i)\bIN\s*\(\s*(?:\?|\$\d+)(?:\s*,\s*(?:\?|\$\d+))*\s*\)`)
+
+// queryDialect describes the lexical rules of string literals in the SQL dialect of a query.
+type queryDialect struct {
+	// Backslashes escape characters in string literals (eg. MySQL).
+	// Otherwise they are only escapes in E'...' strings (eg. PostgreSQL with standard_conforming_strings).
+	backslashEscapes bool
+
+	// Dollar-quoted string literals (eg. $$...$$, $tag$...$tag$ in PostgreSQL).
+	dollarQuotes bool
+}
+
+// dialectOf returns the lexical rules of a gorm dialect.
+// Unknown dialects accept both backslash escapes and dollar-quoted strings.
+func dialectOf(dialect string) queryDialect {
+	switch dialect {
+	case "mysql":
+		return queryDialect{backslashEscapes: true}
+
+	case "postgres":
+		return queryDialect{dollarQuotes: true}
+
+	case "sqlite3", "mssql":
+		return queryDialect{}
+
+	default:
+		return queryDialect{backslashEscapes: true, dollarQuotes: true}
+	}
+}
+
+// obfuscateQuery replaces string and numeric literals in a query (of the given gorm dialect) with placeholders.
+//
+// It is a simple tokenizer, not a full SQL parser:
+// quoted identifiers, identifiers containing numbers and existing placeholders are left untouched,
+// lists of placeholders in IN clauses are collapsed to a single placeholder.
+func obfuscateQuery(query string, dialect string) string {
+	d := dialectOf(dialect)
+
+	var b strings.Builder
+
+	b.Grow(len(query))
+
+	for i := 0; i < len(query); {
+		ch := query[i]
+
+		switch {
+		case ch == '\'':
+			i = skipString(query, i, d.backslashEscapes)
+			b.WriteByte('?')
+
+		case (ch == 'E' || ch == 'e') && i+1 < len(query) && query[i+1] == '\'':
+			// Escape string constant (eg. E'It\'s')
+			i = skipString(query, i+1, true)
+			b.WriteByte('?')
+
+		case ch == '"' || ch == '`':
+			j := skipQuotedIdentifier(query, i)
+			b.WriteString(query[i:j])
+			i = j
+
+		case ch == '$' && i+1 < len(query) && isDigit(query[i+1]):
+			j := i + 1
+			for j < len(query) && isDigit(query[j]) {
+				j++
+			}
+			b.WriteString(query[i:j])
+			i = j
+
+		case ch == '$' && d.dollarQuotes && dollarQuoteTag(query, i) != "":
+			i = skipDollarQuotedString(query, i)
+			b.WriteByte('?')
+
+		case isDigit(ch) || (ch == '.' && i+1 < len(query) && isDigit(query[i+1])):
+			i = skipNumber(query, i)
+			b.WriteByte('?')
+
+		case isIdentifierStart(ch):
+			j := i + 1
+			for j < len(query) && isIdentifierPart(query[j]) {
+				j++
+			}
+			b.WriteString(query[i:j])
+			i = j
+
+		default:
+			b.WriteByte(ch)
+			i++
+		}
+	}
+
+	return inListRegexp.ReplaceAllStringFunc(b.String(), func(list string) string {
+		return list[:2] + " (?)"
+	})
+}
+
+// skipString returns the position after the string literal starting at i.
+// Doubled quotes are always supported, backslash escaped quotes only if backslashEscapes is true.
+func skipString(query string, i int, backslashEscapes bool) int {
+	for j := i + 1; j < len(query); j++ {
+		switch query[j] {
+		case '\\':
+			if backslashEscapes {
+				j++
+			}
+
+		case '\'':
+			if j+1 < len(query) && query[j+1] == '\'' {
+				j++
+
+				continue
+			}
+
+			return j + 1
+		}
+	}
+
+	return len(query)
+}
+
+// dollarQuoteTag returns the opening tag (eg. $$, $body$) of the dollar-quoted string starting at i,
+// or an empty string if there is none.
+func dollarQuoteTag(query string, i int) string {
+	j := i + 1
+
+	if j < len(query) && isIdentifierStart(query[j]) {
+		for j < len(query) && isIdentifierPart(query[j]) && query[j] != '$' {
+			j++
+		}
+	}
+
+	if j < len(query) && query[j] == '$' {
+		return query[i : j+1]
+	}
+
+	return ""
+}
+
+// skipDollarQuotedString returns the position after the dollar-quoted string starting at i.
+func skipDollarQuotedString(query string, i int) int {
+	tag := dollarQuoteTag(query, i)
+
+	end := strings.Index(query[i+len(tag):], tag)
+	if end < 0 {
+		return len(query)
+	}
+
+	return i + len(tag) + end + len(tag)
+}
+
+// skipQuotedIdentifier returns the position after the quoted identifier starting at i.
+func skipQuotedIdentifier(query string, i int) int {
+	quote := query[i]
+
+	for j := i + 1; j < len(query); j++ {
+		if query[j] == quote {
+			return j + 1
+		}
+	}
+
+	return len(query)
+}
+
+// skipNumber returns the position after the numeric literal starting at i.
+func skipNumber(query string, i int) int {
+	j := i
+
+	// Hexadecimal literal
+	if query[j] == '0' && j+1 < len(query) && (query[j+1] == 'x' || query[j+1] == 'X') {
+		j += 2
+		for j < len(query) && isHexDigit(query[j]) {
+			j++
+		}
+
+		return j
+	}
+
+	for j < len(query) && (isDigit(query[j]) || query[j] == '.') {
+		j++
+	}
+
+	// Exponent
+	if j < len(query) && (query[j] == 'e' || query[j] == 'E') {
+		k := j + 1
+		if k < len(query) && (query[k] == '+' || query[k] == '-') {
+			k++
+		}
+
+		if k < len(query) && isDigit(query[k]) {
+			j = k
+			for j < len(query) && isDigit(query[j]) {
+				j++
+			}
+		}
+	}
+
+	return j
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+func isHexDigit(ch byte) bool {
+	return isDigit(ch) || (ch >= 'a' && ch <= 'f') || (ch >= 'A' && ch <= 'F')
+}
+
+func isIdentifierStart(ch byte) bool {
+	return (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || ch == '_' || ch >= 0x80
+}
+
+func isIdentifierPart(ch byte) bool {
+	return isIdentifierStart(ch) || isDigit(ch) || ch == '$'
+}
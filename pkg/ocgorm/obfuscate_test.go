@@ -0,0 +1,118 @@
+package ocgorm
+
+import (
+	"testing"
+)
+
+func TestObfuscateQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		dialect  string
+		query    string
+		expected string
+	}{
+		// MySQL
+		{
+			"mysql select",
+			"mysql",
+			"SELECT * FROM `people` WHERE `first_name` = 'John' AND age > 42",
+			"SELECT * FROM `people` WHERE `first_name` = ? AND age > ?",
+		},
+		{
+			"mysql backslash escaped quote",
+			"mysql",
+			`SELECT * FROM people WHERE name = 'O\'Reilly' AND id = 1`,
+			"SELECT * FROM people WHERE name = ? AND id = ?",
+		},
+		{
+			"mysql doubled quote",
+			"mysql",
+			"SELECT * FROM people WHERE name = 'O''Reilly'",
+			"SELECT * FROM people WHERE name = ?",
+		},
+		{
+			"mysql insert",
+			"mysql",
+			"INSERT INTO `people` (`first_name`,`score`) VALUES ('John',1.5e3),('Jane',0x1F)",
+			"INSERT INTO `people` (`first_name`,`score`) VALUES (?,?),(?,?)",
+		},
+		{
+			"mysql in list",
+			"mysql",
+			"SELECT * FROM people WHERE id IN (1, 2, 3)",
+			"SELECT * FROM people WHERE id IN (?)",
+		},
+		{
+			"mysql placeholders",
+			"mysql",
+			"SELECT * FROM people WHERE id IN (?,?,?) LIMIT 10",
+			"SELECT * FROM people WHERE id IN (?) LIMIT ?",
+		},
+		{
+			"mysql numbers in identifiers",
+			"mysql",
+			"SELECT col1, t2.col_3 FROM table2 t2 WHERE v2 = 5",
+			"SELECT col1, t2.col_3 FROM table2 t2 WHERE v2 = ?",
+		},
+
+		// PostgreSQL
+		{
+			"postgres select",
+			"postgres",
+			`SELECT * FROM "people" WHERE "first_name" = 'John' AND "age" > 42`,
+			`SELECT * FROM "people" WHERE "first_name" = ? AND "age" > ?`,
+		},
+		{
+			"postgres placeholders",
+			"postgres",
+			`SELECT * FROM "people" WHERE "id" IN ($1,$2,$3) AND "deleted_at" IS NULL`,
+			`SELECT * FROM "people" WHERE "id" IN (?) AND "deleted_at" IS NULL`,
+		},
+		{
+			"postgres standard conforming strings",
+			"postgres",
+			`SELECT * FROM files WHERE path = 'C:\' AND size > 10`,
+			"SELECT * FROM files WHERE path = ? AND size > ?",
+		},
+		{
+			"postgres escape string",
+			"postgres",
+			`SELECT * FROM people WHERE name = E'O\'Reilly' AND id = 1`,
+			"SELECT * FROM people WHERE name = ? AND id = ?",
+		},
+		{
+			"postgres dollar quoted string",
+			"postgres",
+			"SELECT $$it's 42$$, id FROM people WHERE id = 1",
+			"SELECT ?, id FROM people WHERE id = ?",
+		},
+		{
+			"postgres tagged dollar quoted string",
+			"postgres",
+			"SELECT $body$ $$ 'not a string' $body$ FROM people WHERE name = 'John'",
+			"SELECT ? FROM people WHERE name = ?",
+		},
+		{
+			"postgres insert returning",
+			"postgres",
+			`INSERT INTO "people" ("first_name") VALUES ('John') RETURNING "people"."id"`,
+			`INSERT INTO "people" ("first_name") VALUES (?) RETURNING "people"."id"`,
+		},
+		{
+			"postgres unterminated dollar quoted string",
+			"postgres",
+			"SELECT $tag$ 'John'",
+			"SELECT ?",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			if actual := obfuscateQuery(test.query, test.dialect); actual != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,13 @@
+module github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgorm/ocgormotel
+
+go 1.15
+
+require (
+	github.com/jinzhu/gorm v1.9.1
+	github.com/sagikazarmark/go-gin-gorm-opencensus v0.0.0
+	go.opencensus.io v0.21.0
+	go.opentelemetry.io/otel v1.0.0
+	go.opentelemetry.io/otel/trace v1.0.0
+)
+
+replace github.com/sagikazarmark/go-gin-gorm-opencensus => ../../..
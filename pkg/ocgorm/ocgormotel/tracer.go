@@ -0,0 +1,167 @@
+// Package ocgormotel makes the ocgorm callbacks emit OpenTelemetry spans instead of OpenCensus spans.
+//
+//	ocgorm.RegisterCallbacks(db, ocgormotel.WithTracerProvider(otel.GetTracerProvider()))
+//
+// Stats are still recorded using OpenCensus.
+// The package is a separate module, so that OpenTelemetry is not a dependency of the ocgorm package.
+package ocgormotel
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"go.opencensus.io/trace"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	oteltrace "go.opentelemetry.io/otel/trace"
+
+	"github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgorm"
+)
+
+// instrumentationName is the name of the OpenTelemetry tracer.
+const instrumentationName = "github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgorm"
+
+// WithTracerProvider makes the callbacks emit OpenTelemetry spans instead of OpenCensus spans (see NewTracer).
+func WithTracerProvider(tp oteltrace.TracerProvider) ocgorm.Option {
+	return ocgorm.WithTracer(NewTracer(tp))
+}
+
+// NewTracer returns an ocgorm.Tracer starting OpenTelemetry spans.
+//
+// OpenCensus spans found in the context are used as remote parents of the OpenTelemetry spans,
+// so traces stay connected while the rest of the application uses OpenCensus.
+// The OpenCensus sampler of the spans (eg. set by ocgorm.Sampler) is applied before the sampler of the tracer provider.
+func NewTracer(tp oteltrace.TracerProvider) ocgorm.Tracer {
+	return &tracer{
+		tracer: tp.Tracer(instrumentationName),
+	}
+}
+
+type tracer struct {
+	tracer oteltrace.Tracer
+}
+
+func (t *tracer) HasSpan(ctx context.Context) bool {
+	return oteltrace.SpanContextFromContext(ctx).IsValid() || trace.FromContext(ctx) != nil
+}
+
+func (t *tracer) StartSpan(ctx context.Context, name string, o trace.StartOptions) (context.Context, ocgorm.Span) {
+	parent := oteltrace.SpanContextFromContext(ctx)
+
+	if !parent.IsValid() {
+		if ocSpan := trace.FromContext(ctx); ocSpan != nil {
+			ocSpanContext := ocSpan.SpanContext()
+
+			parent = oteltrace.NewSpanContext(oteltrace.SpanContextConfig{
+				TraceID:    oteltrace.TraceID(ocSpanContext.TraceID),
+				SpanID:     oteltrace.SpanID(ocSpanContext.SpanID),
+				TraceFlags: oteltrace.TraceFlags(ocSpanContext.TraceOptions),
+				Remote:     true,
+			})
+
+			ctx = oteltrace.ContextWithRemoteSpanContext(ctx, parent)
+		}
+	}
+
+	if o.Sampler != nil && !sampled(o.Sampler, parent, name) {
+		// Non-recording span
+		return ctx, span{oteltrace.SpanFromContext(context.Background())}
+	}
+
+	ctx, s := t.tracer.Start(ctx, name, oteltrace.WithSpanKind(spanKind(o.SpanKind)))
+
+	return ctx, span{s}
+}
+
+var (
+	idRand   = rand.New(rand.NewSource(time.Now().UnixNano()))
+	idRandMu sync.Mutex
+)
+
+// sampled makes a sampling decision using an OpenCensus sampler.
+func sampled(sampler trace.Sampler, parent oteltrace.SpanContext, name string) bool {
+	params := trace.SamplingParameters{
+		Name:            name,
+		HasRemoteParent: parent.IsRemote(),
+	}
+
+	idRandMu.Lock()
+	if parent.IsValid() {
+		params.ParentContext = trace.SpanContext{
+			TraceID:      trace.TraceID(parent.TraceID()),
+			SpanID:       trace.SpanID(parent.SpanID()),
+			TraceOptions: trace.TraceOptions(parent.TraceFlags()),
+		}
+		params.TraceID = params.ParentContext.TraceID
+	} else {
+		_, _ = idRand.Read(params.TraceID[:])
+	}
+	_, _ = idRand.Read(params.SpanID[:])
+	idRandMu.Unlock()
+
+	return sampler(params).Sample
+}
+
+// spanKind converts an OpenCensus span kind to an OpenTelemetry span kind.
+func spanKind(kind int) oteltrace.SpanKind {
+	switch kind {
+	case trace.SpanKindClient:
+		return oteltrace.SpanKindClient
+
+	case trace.SpanKindServer:
+		return oteltrace.SpanKindServer
+
+	default:
+		return oteltrace.SpanKindInternal
+	}
+}
+
+// span adapts an OpenTelemetry span to ocgorm.Span.
+type span struct {
+	span oteltrace.Span
+}
+
+func (s span) AddAttributes(attributes ...ocgorm.Attribute) {
+	s.span.SetAttributes(convertAttributes(attributes)...)
+}
+
+func (s span) Annotate(attributes []ocgorm.Attribute, str string) {
+	s.span.AddEvent(str, oteltrace.WithAttributes(convertAttributes(attributes)...))
+}
+
+func (s span) SetStatus(status trace.Status) {
+	if status.Code == trace.StatusCodeOK {
+		return
+	}
+
+	s.span.SetStatus(codes.Error, status.Message)
+}
+
+func (s span) End() {
+	s.span.End()
+}
+
+// convertAttributes converts span attributes to OpenTelemetry attributes.
+func convertAttributes(attributes []ocgorm.Attribute) []attribute.KeyValue {
+	kvs := make([]attribute.KeyValue, 0, len(attributes))
+
+	for _, a := range attributes {
+		switch v := a.Value.(type) {
+		case bool:
+			kvs = append(kvs, attribute.Bool(a.Key, v))
+
+		case int64:
+			kvs = append(kvs, attribute.Int64(a.Key, v))
+
+		case float64:
+			kvs = append(kvs, attribute.Float64(a.Key, v))
+
+		case string:
+			kvs = append(kvs, attribute.String(a.Key, v))
+		}
+	}
+
+	return kvs
+}
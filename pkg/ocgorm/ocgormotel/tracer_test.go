@@ -0,0 +1,260 @@
+package ocgormotel_test
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+	"go.opencensus.io/trace"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	oteltrace "go.opentelemetry.io/otel/trace"
+
+	"github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgorm"
+	"github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgorm/ocgormotel"
+	"github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgorm/ocgormtest"
+)
+
+// tracerProvider is an in-memory OpenTelemetry tracer provider recording every started span.
+type tracerProvider struct {
+	mu    sync.Mutex
+	spans []*recordedSpan
+}
+
+func (p *tracerProvider) Tracer(string, ...oteltrace.TracerOption) oteltrace.Tracer {
+	return testTracer{p}
+}
+
+func (p *tracerProvider) spansWithName(name string) []*recordedSpan {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var spans []*recordedSpan
+
+	for _, span := range p.spans {
+		if span.name == name {
+			spans = append(spans, span)
+		}
+	}
+
+	return spans
+}
+
+type testTracer struct {
+	p *tracerProvider
+}
+
+func (t testTracer) Start(ctx context.Context, name string, opts ...oteltrace.SpanStartOption) (context.Context, oteltrace.Span) {
+	parent := oteltrace.SpanContextFromContext(ctx)
+
+	traceID := parent.TraceID()
+	if !parent.IsValid() {
+		traceID = oteltrace.TraceID{1}
+	}
+
+	t.p.mu.Lock()
+	defer t.p.mu.Unlock()
+
+	span := &recordedSpan{
+		name:   name,
+		parent: parent,
+		config: oteltrace.NewSpanStartConfig(opts...),
+		spanContext: oteltrace.NewSpanContext(oteltrace.SpanContextConfig{
+			TraceID:    traceID,
+			SpanID:     oteltrace.SpanID{byte(len(t.p.spans) + 1)},
+			TraceFlags: oteltrace.FlagsSampled,
+		}),
+		attributes: make(map[attribute.Key]attribute.Value),
+		provider:   t.p,
+	}
+
+	t.p.spans = append(t.p.spans, span)
+
+	return oteltrace.ContextWithSpan(ctx, span), span
+}
+
+type recordedSpan struct {
+	name        string
+	parent      oteltrace.SpanContext
+	config      oteltrace.SpanConfig
+	spanContext oteltrace.SpanContext
+	attributes  map[attribute.Key]attribute.Value
+	events      []string
+	statusCode  codes.Code
+	ended       bool
+	provider    *tracerProvider
+}
+
+func (s *recordedSpan) End(...oteltrace.SpanEndOption) {
+	s.ended = true
+}
+
+func (s *recordedSpan) AddEvent(name string, _ ...oteltrace.EventOption) {
+	s.events = append(s.events, name)
+}
+
+func (s *recordedSpan) IsRecording() bool {
+	return !s.ended
+}
+
+func (s *recordedSpan) RecordError(error, ...oteltrace.EventOption) {}
+
+func (s *recordedSpan) SpanContext() oteltrace.SpanContext {
+	return s.spanContext
+}
+
+func (s *recordedSpan) SetStatus(code codes.Code, _ string) {
+	s.statusCode = code
+}
+
+func (s *recordedSpan) SetName(name string) {
+	s.name = name
+}
+
+func (s *recordedSpan) SetAttributes(kv ...attribute.KeyValue) {
+	for _, attr := range kv {
+		s.attributes[attr.Key] = attr.Value
+	}
+}
+
+func (s *recordedSpan) TracerProvider() oteltrace.TracerProvider {
+	return s.provider
+}
+
+type Person struct {
+	ID        uint
+	FirstName string
+}
+
+func newTestDB(t *testing.T, opts ...ocgorm.Option) *gorm.DB {
+	t.Helper()
+
+	sqlDB, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every connection has its own in-memory database
+	sqlDB.SetMaxOpenConns(1)
+
+	db, err := ocgormtest.NewMockDB("sqlite3", sqlDB, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.AutoMigrate(&Person{}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func TestTracer(t *testing.T) {
+	tp := &tracerProvider{}
+
+	var modified []ocgorm.Span
+
+	db := newTestDB(
+		t,
+		ocgormotel.WithTracerProvider(tp),
+		ocgorm.SpanAttributes{ocgorm.StringAttribute("tenant", "acme"), ocgorm.Int64Attribute("shard", 3)},
+		ocgorm.SpanModifier(func(ctx context.Context, span ocgorm.Span, scope *gorm.Scope) {
+			modified = append(modified, span)
+
+			span.AddAttributes(ocgorm.BoolAttribute("modified", true), ocgorm.Float64Attribute("weight", 0.5))
+		}),
+	)
+
+	ctx, parent := trace.StartSpan(context.Background(), "parent", trace.WithSampler(trace.AlwaysSample()))
+
+	if err := ocgorm.WithContext(ctx, db).Create(&Person{FirstName: "John"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	parent.End()
+
+	spans := tp.spansWithName("gorm:create")
+	if len(spans) != 1 {
+		t.Fatalf("expected one create span, got %d", len(spans))
+	}
+
+	span := spans[0]
+
+	if !span.ended {
+		t.Error("the span is not ended")
+	}
+
+	if span.config.SpanKind() != oteltrace.SpanKindClient {
+		t.Errorf("expected span kind %s, got %s", oteltrace.SpanKindClient, span.config.SpanKind())
+	}
+
+	if !span.parent.IsRemote() || span.parent.TraceID() != oteltrace.TraceID(parent.SpanContext().TraceID) {
+		t.Error("expected the OpenCensus span to be the remote parent of the span")
+	}
+
+	expected := map[attribute.Key]attribute.Value{
+		"tenant":                  attribute.StringValue("acme"),
+		"shard":                   attribute.Int64Value(3),
+		"modified":                attribute.BoolValue(true),
+		"weight":                  attribute.Float64Value(0.5),
+		ocgorm.OperationAttribute: attribute.StringValue(ocgorm.OpCreate),
+		ocgorm.TableAttribute:     attribute.StringValue("people"),
+	}
+
+	for key, value := range expected {
+		if actual := span.attributes[key]; actual != value {
+			t.Errorf("expected attribute %s to be %v, got %v", key, value.Emit(), actual.Emit())
+		}
+	}
+
+	// Called right after start and before end
+	if len(modified) != 2 {
+		t.Errorf("expected the span modifier to be called twice, got %d", len(modified))
+	}
+}
+
+func TestTracer_Sampler(t *testing.T) {
+	tp := &tracerProvider{}
+
+	db := newTestDB(t, ocgormotel.WithTracerProvider(tp), ocgorm.Sampler(trace.NeverSample()))
+
+	ctx, parent := trace.StartSpan(context.Background(), "parent", trace.WithSampler(trace.AlwaysSample()))
+
+	if err := ocgorm.WithContext(ctx, db).Create(&Person{FirstName: "John"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	parent.End()
+
+	if spans := tp.spansWithName("gorm:create"); len(spans) != 0 {
+		t.Errorf("expected no spans to be started, got %d", len(spans))
+	}
+}
+
+func TestTracer_Error(t *testing.T) {
+	tp := &tracerProvider{}
+
+	db := newTestDB(t, ocgormotel.WithTracerProvider(tp), ocgorm.AllowRoot(true))
+
+	if err := db.Exec("INSERT INTO unknown (id) VALUES (1)").Error; err == nil {
+		t.Fatal("expected an error")
+	}
+
+	var people []Person
+
+	if err := db.Table("unknown").Find(&people).Error; err == nil {
+		t.Fatal("expected an error")
+	}
+
+	spans := tp.spansWithName("gorm:query")
+	if len(spans) != 1 {
+		t.Fatalf("expected one query span, got %d", len(spans))
+	}
+
+	if spans[0].statusCode != codes.Error {
+		t.Errorf("expected an error status, got %s", spans[0].statusCode)
+	}
+}
@@ -0,0 +1,135 @@
+// Package ocgormtest provides helpers for testing code instrumented by ocgorm.
+package ocgormtest
+
+import (
+	"database/sql"
+	"sync"
+
+	"github.com/jinzhu/gorm"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"go.opencensus.io/trace"
+
+	"github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgorm"
+)
+
+// Exporter is an in-memory trace exporter collecting every exported span.
+type Exporter struct {
+	mu    sync.Mutex
+	spans []*trace.SpanData
+}
+
+// NewExporter returns a new Exporter registered as a trace exporter.
+//
+// The returned function unregisters the exporter.
+func NewExporter() (*Exporter, func()) {
+	e := &Exporter{}
+
+	trace.RegisterExporter(e)
+
+	return e, func() { trace.UnregisterExporter(e) }
+}
+
+// ExportSpan implements the trace.Exporter interface.
+func (e *Exporter) ExportSpan(s *trace.SpanData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.spans = append(e.spans, s)
+}
+
+// Spans returns every exported span.
+func (e *Exporter) Spans() []*trace.SpanData {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return append([]*trace.SpanData(nil), e.spans...)
+}
+
+// SpansWithName returns the exported spans with the given name.
+func (e *Exporter) SpansWithName(name string) []*trace.SpanData {
+	var spans []*trace.SpanData
+
+	for _, s := range e.Spans() {
+		if s.Name == name {
+			spans = append(spans, s)
+		}
+	}
+
+	return spans
+}
+
+// SpansWithAttribute returns the exported spans having an attribute with the given value.
+func (e *Exporter) SpansWithAttribute(key string, value interface{}) []*trace.SpanData {
+	var spans []*trace.SpanData
+
+	for _, s := range e.Spans() {
+		if v, ok := s.Attributes[key]; ok && v == value {
+			spans = append(spans, s)
+		}
+	}
+
+	return spans
+}
+
+// Reset removes every collected span.
+func (e *Exporter) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.spans = nil
+}
+
+// ViewRows returns a snapshot of the rows of a registered view having all of the given tags.
+func ViewRows(v *view.View, tags ...tag.Tag) ([]*view.Row, error) {
+	rows, err := view.RetrieveData(v.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*view.Row
+
+	for _, row := range rows {
+		if hasTags(row, tags) {
+			result = append(result, row)
+		}
+	}
+
+	return result, nil
+}
+
+func hasTags(row *view.Row, tags []tag.Tag) bool {
+	for _, t := range tags {
+		found := false
+
+		for _, rt := range row.Tags {
+			if rt == t {
+				found = true
+
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// NewMockDB opens a gorm database on top of an existing connection (eg. one created by go-sqlmock)
+// and registers the ocgorm callbacks.
+//
+//	sqlDB, mock, _ := sqlmock.New()
+//	db, _ := ocgormtest.NewMockDB("mysql", sqlDB, ocgorm.AllowRoot(true))
+func NewMockDB(dialect string, sqlDB *sql.DB, opts ...ocgorm.Option) (*gorm.DB, error) {
+	db, err := gorm.Open(dialect, sqlDB)
+	if err != nil {
+		return nil, err
+	}
+
+	ocgorm.RegisterCallbacks(db, opts...)
+
+	return db, nil
+}
@@ -0,0 +1,235 @@
+package ocgorm
+
+import (
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/jinzhu/gorm"
+)
+
+// maxQueryVarLength is the maximum length of a single rendered query variable.
+const maxQueryVarLength = 128
+
+// formatQueryVars renders query bind variables in a human readable form.
+func formatQueryVars(vars []interface{}) string {
+	formatted := make([]string, 0, len(vars))
+
+	for _, v := range vars {
+		formatted = append(formatted, truncateQueryVar(formatQueryVar(v)))
+	}
+
+	return "[" + strings.Join(formatted, ", ") + "]"
+}
+
+func formatQueryVar(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+
+	case []byte:
+		if !utf8.Valid(v) {
+			return fmt.Sprintf("<binary %d bytes>", len(v))
+		}
+
+		return fmt.Sprintf("%q", v)
+
+	case string:
+		return fmt.Sprintf("%q", v)
+
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+
+	case *time.Time:
+		if v == nil {
+			return "NULL"
+		}
+
+		return v.Format(time.RFC3339Nano)
+
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+func truncateQueryVar(v string) string {
+	if len(v) <= maxQueryVarLength {
+		return v
+	}
+
+	length := maxQueryVarLength
+
+	// Do not cut multi-byte characters in half
+	for length > 0 && !utf8.RuneStart(v[length]) {
+		length--
+	}
+
+	return v[:length] + "..."
+}
+
+// truncateQuery truncates a query to the given length (zero meaning no limit).
+func truncateQuery(query string, length int) string {
+	if length <= 0 || len(query) <= length {
+		return query
+	}
+
+	// Do not cut multi-byte characters in half
+	for length > 0 && !utf8.RuneStart(query[length]) {
+		length--
+	}
+
+	return fmt.Sprintf("%s... [truncated %d bytes]", query[:length], len(query)-length)
+}
+
+// tableRegexp matches table references in a query.
+var tableRegexp = regexp.MustCompile("(?i)\\b(?:FROM|JOIN|INTO|UPDATE)\\s+([`\"]?[\\w$]+[`\"]?(?:\\.[`\"]?[\\w$]+[`\"]?)?)")
+
+// queryTables returns the tables referenced in a query (best effort).
+func queryTables(query string) []string {
+	var tables []string
+
+	seen := make(map[string]bool)
+
+	for _, match := range tableRegexp.FindAllStringSubmatch(query, -1) {
+		table := strings.NewReplacer("`", "", `"`, "").Replace(match[1])
+
+		if seen[table] {
+			continue
+		}
+
+		seen[table] = true
+		tables = append(tables, table)
+	}
+
+	return tables
+}
+
+// queryVerb returns the type of a query based on its first keyword (SELECT, INSERT, UPDATE, DELETE or OTHER).
+// It returns an empty string for empty queries.
+func queryVerb(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return ""
+	}
+
+	switch verb := strings.ToUpper(strings.TrimLeft(fields[0], "(")); verb {
+	case "SELECT", "INSERT", "UPDATE", "DELETE":
+		return verb
+
+	default:
+		return "OTHER"
+	}
+}
+
+// queryFingerprint returns the normalized form of a query (literals replaced with placeholders, whitespace collapsed)
+// and a short hash of it. The same logical query always produces the same fingerprint.
+func queryFingerprint(query string, dialect string) (string, string) {
+	normalized := strings.Join(strings.Fields(obfuscateQuery(query, dialect)), " ")
+
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(normalized))
+
+	return normalized, fmt.Sprintf("%016x", h.Sum64())
+}
+
+// valuesRegexp matches the VALUES keyword of an INSERT statement.
+var valuesRegexp = regexp.MustCompile(`(?i)\bVALUES\b`)
+
+// onDuplicateKeyRegexp matches the ON DUPLICATE KEY UPDATE clause of MySQL upserts.
+var onDuplicateKeyRegexp = regexp.MustCompile(`(?i)\bON\s+DUPLICATE\s+KEY\s+UPDATE\b`)
+
+// queryValueTuples returns the number of value tuples in an INSERT statement (zero if it cannot be detected).
+func queryValueTuples(query string) int {
+	loc := valuesRegexp.FindStringIndex(query)
+	if loc == nil {
+		return 0
+	}
+
+	var (
+		tuples int
+		depth  int
+		quote  rune
+	)
+
+	for _, r := range query[loc[1]:] {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+
+		case r == '(':
+			if depth == 0 {
+				tuples++
+			}
+
+			depth++
+
+		case r == ')':
+			depth--
+
+		case depth == 0 && r != ',' && !unicode.IsSpace(r):
+			// End of the VALUES list (eg. ON DUPLICATE KEY UPDATE, RETURNING)
+			return tuples
+		}
+	}
+
+	return tuples
+}
+
+// countQueryRegexp matches the queries built by gorm.DB.Count.
+var countQueryRegexp = regexp.MustCompile(`(?i)^\s*SELECT\s+count\(`)
+
+// apiOperation returns the gorm API executing a query of the operation:
+// Scan (based on its destination) for queries,
+// Count (based on the query) and Pluck (based on the stack, if detectPluck is true) for row queries.
+// It returns an empty string if the API cannot be determined (eg. Find, Row, Rows).
+func apiOperation(scope *gorm.Scope, operation interface{}, detectPluck bool) string {
+	switch operation {
+	case OpQuery:
+		if _, ok := scope.Get("gorm:query_destination"); ok {
+			return OpScan
+		}
+
+	case OpRowQuery:
+		if countQueryRegexp.MatchString(scope.SQL) {
+			return OpCount
+		}
+
+		result, _ := scope.InstanceGet("row_query_result")
+
+		if _, ok := result.(*gorm.RowsQueryResult); ok && detectPluck && calledBy("github.com/jinzhu/gorm.(*DB).Pluck") {
+			return OpPluck
+		}
+	}
+
+	return ""
+}
+
+// calledBy reports whether function is on the stack.
+func calledBy(function string) bool {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(3, pcs)
+
+	frames := runtime.CallersFrames(pcs[:n])
+
+	for {
+		frame, more := frames.Next()
+
+		if frame.Function == function {
+			return true
+		}
+
+		if !more {
+			return false
+		}
+	}
+}
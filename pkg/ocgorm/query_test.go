@@ -0,0 +1,121 @@
+package ocgorm
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestFormatQueryVars(t *testing.T) {
+	ts := time.Date(2019, 5, 1, 12, 30, 0, 0, time.UTC)
+
+	vars := []interface{}{nil, 42, "John", []byte("Doe"), []byte{0xff, 0xfe}, ts, &ts, (*time.Time)(nil)}
+
+	expected := `[NULL, 42, "John", "Doe", <binary 2 bytes>, 2019-05-01T12:30:00Z, 2019-05-01T12:30:00Z, NULL]`
+
+	if actual := formatQueryVars(vars); actual != expected {
+		t.Errorf("expected %s, got %s", expected, actual)
+	}
+}
+
+func TestTruncateQueryVar(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"ascii", strings.Repeat("a", 200)},
+		{"multi-byte", strings.Repeat("é", 100)},
+		{"multi-byte with offset", "a" + strings.Repeat("日", 100)},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			truncated := truncateQueryVar(test.value)
+
+			if !utf8.ValidString(truncated) {
+				t.Errorf("truncated value is not valid UTF-8: %q", truncated)
+			}
+
+			if !strings.HasSuffix(truncated, "...") || len(truncated) > maxQueryVarLength+len("...") {
+				t.Errorf("value is not truncated to %d bytes: %q", maxQueryVarLength, truncated)
+			}
+		})
+	}
+
+	if actual := truncateQueryVar("short"); actual != "short" {
+		t.Errorf("short values must not be truncated, got %q", actual)
+	}
+}
+
+func TestQueryValueTuples(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		expected int
+	}{
+		{"single row", "INSERT INTO people (first_name) VALUES (?)", 1},
+		{"multiple rows", "INSERT INTO people (first_name, last_name) VALUES (?, ?), (?, ?), (?, ?)", 3},
+		{"lowercase", "insert into people (first_name) values ('a'),('b')", 2},
+		{"nested parentheses", "INSERT INTO people (first_name) VALUES (LOWER(?)), (COALESCE(?, UPPER(?)))", 2},
+		{"quoted parentheses", "INSERT INTO people (first_name) VALUES ('(John'), ('Jane)'), (\"(\")", 3},
+		{"quoted quotes", "INSERT INTO people (first_name) VALUES ('it''s'), ('(')", 2},
+		{"backquoted identifiers", "INSERT INTO `people` (`first_name`) VALUES (?), (?)", 2},
+		{"returning", `INSERT INTO "people" ("first_name") VALUES ($1), ($2) RETURNING "people"."id"`, 2},
+		{"on duplicate key", "INSERT INTO people (id, first_name) VALUES (?, ?), (?, ?) ON DUPLICATE KEY UPDATE first_name = VALUES(first_name)", 2},
+		{"on conflict", "INSERT INTO people (id) VALUES (1), (2), (3) ON CONFLICT (id) DO NOTHING", 3},
+		{"insert select", "INSERT INTO people (first_name) SELECT first_name FROM authors", 0},
+		{"select", "SELECT * FROM people", 0},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			if actual := queryValueTuples(test.query); actual != test.expected {
+				t.Errorf("expected %d value tuples, got %d", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestBatchAttributes(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		rows     int64
+		expected []Attribute
+	}{
+		{
+			name:     "single row",
+			query:    "INSERT INTO people (first_name) VALUES (?)",
+			rows:     1,
+			expected: nil,
+		},
+		{
+			name:     "multiple rows",
+			query:    "INSERT INTO people (first_name) VALUES (?), (?), (?)",
+			rows:     3,
+			expected: []Attribute{Int64Attribute(BatchRowsAttribute, 3), Int64Attribute(BatchTuplesAttribute, 3)},
+		},
+		{
+			name:     "on duplicate key",
+			query:    "INSERT INTO people (id, first_name) VALUES (?, ?), (?, ?) ON DUPLICATE KEY UPDATE first_name = VALUES(first_name)",
+			rows:     4,
+			expected: []Attribute{Int64Attribute(BatchTuplesAttribute, 2)},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			if actual := batchAttributes(test.query, test.rows); !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf("expected attributes %v, got %v", test.expected, actual)
+			}
+		})
+	}
+}
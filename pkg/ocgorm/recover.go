@@ -0,0 +1,105 @@
+package ocgorm
+
+import (
+	"fmt"
+
+	"github.com/jinzhu/gorm"
+	"go.opencensus.io/trace"
+)
+
+// Gorm scope keys
+var (
+	panicScopeKey = "_opencensusPanic"
+)
+
+// spanCallbacks are the gorm callbacks running within the span of each operation.
+var spanCallbacks = map[string][]string{
+	OpCreate:   {"gorm:create"},
+	OpQuery:    {"gorm:query", "gorm:preload"},
+	OpRowQuery: {"gorm:row_query"},
+	OpUpdate:   {"gorm:update"},
+	OpDelete:   {"gorm:delete"},
+}
+
+// wrappedSpanCallbacks are the gorm callbacks running within the span of each operation
+// when the instrumentation wraps the callback chains (see WrapCallbacks), including the model hooks.
+var wrappedSpanCallbacks = map[string][]string{
+	OpCreate: {
+		"gorm:begin_transaction",
+		"gorm:before_create",
+		"gorm:save_before_associations",
+		"gorm:update_time_stamp",
+		"gorm:create",
+		"gorm:force_reload_after_create",
+		"gorm:save_after_associations",
+		"gorm:after_create",
+		"gorm:commit_or_rollback_transaction",
+	},
+	OpQuery:    {"gorm:query", "gorm:preload", "gorm:after_query"},
+	OpRowQuery: {"gorm:row_query"},
+	OpUpdate: {
+		"gorm:begin_transaction",
+		"gorm:before_update",
+		"gorm:save_before_associations",
+		"gorm:update_time_stamp",
+		"gorm:update",
+		"gorm:save_after_associations",
+		"gorm:after_update",
+		"gorm:commit_or_rollback_transaction",
+	},
+	OpDelete: {
+		"gorm:begin_transaction",
+		"gorm:before_delete",
+		"gorm:delete",
+		"gorm:after_delete",
+		"gorm:commit_or_rollback_transaction",
+	},
+}
+
+// recoverCallbacks replaces the gorm callbacks running within the span of an operation
+// with ones ending the span if they panic (see recoverCallback).
+func (c *callbacks) recoverCallbacks(processor func() *gorm.CallbackProcessor, operation string) {
+	names := spanCallbacks[operation]
+	if c.wrapCallbacks {
+		names = wrappedSpanCallbacks[operation]
+	}
+
+	for _, name := range names {
+		c.recoverCallback(processor, name)
+	}
+}
+
+// recoverCallback replaces a gorm callback with one ending the span of the operation if the callback panics.
+// Otherwise the after callbacks would never run, leaking the span.
+func (c *callbacks) recoverCallback(processor func() *gorm.CallbackProcessor, name string) {
+	callback := processor().Get(name)
+	if callback == nil {
+		return
+	}
+
+	processor().Replace(name, func(scope *gorm.Scope) {
+		defer func() {
+			if r := recover(); r != nil {
+				scope.InstanceSet(panicScopeKey, r)
+				_ = scope.Err(fmt.Errorf("panic: %v", r))
+
+				c.endStatement(scope)
+				c.after(scope)
+
+				panic(r)
+			}
+		}()
+
+		callback(scope)
+	})
+}
+
+// panicStatus returns the span status of operations interrupted by a panic.
+func panicStatus(scope *gorm.Scope) (trace.Status, bool) {
+	r, ok := scope.InstanceGet(panicScopeKey)
+	if !ok {
+		return trace.Status{}, false
+	}
+
+	return trace.Status{Code: trace.StatusCodeInternal, Message: fmt.Sprintf("panic: %v", r)}, true
+}
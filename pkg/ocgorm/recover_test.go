@@ -0,0 +1,57 @@
+package ocgorm_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	"go.opencensus.io/trace"
+
+	"github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgorm"
+	"github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgorm/ocgormtest"
+)
+
+type PanickingHook struct {
+	ID   uint
+	Name string
+}
+
+func (p *PanickingHook) BeforeCreate() error {
+	panic("hook failed")
+}
+
+// createPanicking creates a record with a panicking hook and returns the recovered panic.
+func createPanicking(ctx context.Context, db *gorm.DB) (r interface{}) {
+	defer func() {
+		r = recover()
+	}()
+
+	ocgorm.WithContext(ctx, db).Create(&PanickingHook{Name: "John"})
+
+	return nil
+}
+
+func TestPanickingHook(t *testing.T) {
+	db := newTestDB(t, ocgorm.WrapCallbacks(true))
+
+	if err := db.AutoMigrate(&PanickingHook{}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	exporter, unregister := ocgormtest.NewExporter()
+	defer unregister()
+
+	ctx, span := trace.StartSpan(context.Background(), "parent")
+
+	if r := createPanicking(ctx, db); r != "hook failed" {
+		t.Errorf("expected the panic to be propagated, got %v", r)
+	}
+
+	span.End()
+
+	create := findSpan(t, exporter, "gorm:create", "panicking_hooks")
+
+	if create.Status.Code != trace.StatusCodeInternal {
+		t.Errorf("expected status code %d, got %d", trace.StatusCodeInternal, create.Status.Code)
+	}
+}
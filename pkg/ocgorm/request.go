@@ -0,0 +1,37 @@
+package ocgorm
+
+import (
+	"context"
+	"sync/atomic"
+
+	"go.opencensus.io/stats"
+)
+
+// requestStatsContextKey is the context key of the query counter of a request.
+type requestStatsContextKey struct{}
+
+// WithRequestStats returns a context counting the operations executed with it (and any derived context)
+// until the count is recorded by FlushRequestStats.
+//
+// Middleware does this for every request.
+func WithRequestStats(ctx context.Context) context.Context {
+	return context.WithValue(ctx, requestStatsContextKey{}, new(int64))
+}
+
+// FlushRequestStats records the number of operations executed with the context since the last flush
+// (see WithRequestStats). Tags in the context (eg. the route of the request) are recorded with the measurement.
+func FlushRequestStats(ctx context.Context) {
+	count, ok := ctx.Value(requestStatsContextKey{}).(*int64)
+	if !ok {
+		return
+	}
+
+	stats.Record(ctx, MeasureRequestQueryCount.M(atomic.SwapInt64(count, 0)))
+}
+
+// countRequestQuery counts an operation executed with a context returned by WithRequestStats.
+func countRequestQuery(ctx context.Context) {
+	if count, ok := ctx.Value(requestStatsContextKey{}).(*int64); ok {
+		atomic.AddInt64(count, 1)
+	}
+}
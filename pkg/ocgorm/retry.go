@@ -0,0 +1,194 @@
+package ocgorm
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+	"go.opencensus.io/trace"
+)
+
+// RetryPolicy configures WithRetry.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts (including the first one).
+	// Defaults to 3.
+	MaxAttempts int
+
+	// Backoff is the delay before each retry.
+	Backoff time.Duration
+
+	// Retryable decides whether a failed attempt should be retried.
+	// Defaults to deadlocks and serialization failures (see IsTransactionConflict)
+	// and errors classified as aborted (see ErrorClassifiers).
+	Retryable func(err error) bool
+}
+
+// WithRetry runs fn in a transaction (see Transaction) on db bound to ctx,
+// and retries it according to the policy if it fails.
+//
+// The attempts are traced as gorm:attempt spans of a gorm:retry span (following the AllowRoot configuration of the callbacks).
+// Every retry is annotated with the attempt number and the status of the error triggering it,
+// and counted in the go.sql/client/transaction/retries measure.
+//
+// The error of the last attempt is returned.
+func WithRetry(ctx context.Context, db *gorm.DB, policy RetryPolicy, fn func(tx *gorm.DB) error) error {
+	c := callbacksFromDB(db)
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	maxAttempts := policy.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 3
+	}
+
+	retryable := policy.Retryable
+	if retryable == nil {
+		retryable = func(err error) bool {
+			return IsTransactionConflict(err) || c.statusFromError(err).Code == trace.StatusCodeAborted
+		}
+	}
+
+	var span *trace.Span
+
+	if !c.disableTrace && (trace.FromContext(ctx) != nil || c.allowRoot) {
+		ctx, span = trace.StartSpan(ctx, "gorm:retry", trace.WithSpanKind(c.spanKind), trace.WithSampler(c.spanSampler()))
+
+		span.AddAttributes(c.defaultAttributes...)
+	}
+
+	var err error
+
+	for attempt := 1; ; attempt++ {
+		err = c.retryAttempt(ctx, db, attempt, fn)
+		if err == nil || attempt >= maxAttempts || !retryable(err) {
+			break
+		}
+
+		if span != nil {
+			status := c.statusFromError(err)
+
+			span.Annotate(
+				[]trace.Attribute{
+					trace.Int64Attribute(RetryAttemptAttribute, int64(attempt)),
+					trace.Int64Attribute("status.code", int64(status.Code)),
+					trace.StringAttribute("status.message", status.Message),
+				},
+				"gorm: retrying transaction",
+			)
+		}
+
+		if !c.disableStats {
+			c.recordRetry(ctx)
+		}
+
+		if policy.Backoff > 0 {
+			timer := time.NewTimer(policy.Backoff)
+
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+			case <-timer.C:
+			}
+		}
+
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	if span != nil {
+		if err != nil {
+			span.SetStatus(c.statusFromError(err))
+		}
+
+		span.End()
+	}
+
+	return err
+}
+
+// retryAttempt runs a single attempt of WithRetry in a gorm:attempt span.
+func (c *callbacks) retryAttempt(ctx context.Context, db *gorm.DB, attempt int, fn func(tx *gorm.DB) error) error {
+	var span *trace.Span
+
+	if !c.disableTrace && trace.FromContext(ctx) != nil {
+		ctx, span = trace.StartSpan(ctx, "gorm:attempt", trace.WithSpanKind(c.spanKind), trace.WithSampler(c.spanSampler()))
+
+		span.AddAttributes(c.defaultAttributes...)
+		span.AddAttributes(trace.Int64Attribute(RetryAttemptAttribute, int64(attempt)))
+	}
+
+	err := Transaction(ctx, db, fn)
+
+	if span != nil {
+		if err != nil {
+			span.SetStatus(c.statusFromError(err))
+		}
+
+		span.End()
+	}
+
+	return err
+}
+
+// recordRetry counts a retried transaction.
+func (c *callbacks) recordRetry(ctx context.Context) {
+	mutators := c.defaultTags[:len(c.defaultTags):len(c.defaultTags)]
+
+	if c.databaseName != "" {
+		mutators = append(mutators, tag.Upsert(Database, c.databaseName))
+	}
+
+	if c.role != "" {
+		mutators = append(mutators, tag.Upsert(DatabaseRole, c.role))
+	}
+
+	ctx, _ = tag.New(ctx, mutators...)
+
+	stats.Record(ctx, c.measures.txRetryCount.M(1))
+}
+
+// IsTransactionConflict reports whether the error (or one of the errors it wraps) is a deadlock,
+// a serialization failure or a lock wait timeout, after which the transaction can be retried:
+// PostgreSQL 40P01 (deadlock_detected) and 40001 (serialization_failure) errors (lib/pq and pgx),
+// MySQL 1213 (ER_LOCK_DEADLOCK) and 1205 (ER_LOCK_WAIT_TIMEOUT) errors.
+//
+// The errors are matched without importing the drivers: PostgreSQL errors by their SQLSTATE, MySQL errors by their message.
+func IsTransactionConflict(err error) bool {
+	for err != nil {
+		switch e := err.(type) {
+		case interface{ SQLState() string }: // pgx
+			if isConflictSQLState(e.SQLState()) {
+				return true
+			}
+
+		case interface{ Get(k byte) string }: // lib/pq
+			if isConflictSQLState(e.Get('C')) {
+				return true
+			}
+		}
+
+		message := err.Error()
+		if strings.HasPrefix(message, "Error 1213:") || strings.HasPrefix(message, "Error 1205:") {
+			return true
+		}
+
+		wrapper, ok := err.(interface{ Unwrap() error })
+		if !ok {
+			return false
+		}
+
+		err = wrapper.Unwrap()
+	}
+
+	return false
+}
+
+func isConflictSQLState(code string) bool {
+	return code == "40P01" || code == "40001"
+}
@@ -0,0 +1,57 @@
+package ocgorm_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+
+	"github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgorm"
+)
+
+func TestWithRetry_DefaultPredicate(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		attempts int
+	}{
+		{"mysql deadlock", &mysql.MySQLError{Number: 1213, Message: "Deadlock found"}, 2},
+		{"mysql lock wait timeout", &mysql.MySQLError{Number: 1205, Message: "Lock wait timeout exceeded"}, 2},
+		{"mysql duplicate entry", &mysql.MySQLError{Number: 1062, Message: "Duplicate entry"}, 1},
+		{"postgres deadlock", &pq.Error{Code: "40P01", Message: "deadlock detected"}, 2},
+		{"postgres serialization failure", &pq.Error{Code: "40001", Message: "could not serialize access"}, 2},
+		{"postgres unique violation", &pq.Error{Code: "23505", Message: "duplicate key value"}, 1},
+		{"other error", errors.New("error"), 1},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			db := newTestDB(t)
+
+			var attempts int
+
+			err := ocgorm.WithRetry(context.Background(), db, ocgorm.RetryPolicy{}, func(tx *gorm.DB) error {
+				attempts++
+
+				if attempts == 1 {
+					return test.err
+				}
+
+				return nil
+			})
+
+			if attempts != test.attempts {
+				t.Errorf("expected %d attempts, got %d", test.attempts, attempts)
+			}
+
+			if test.attempts > 1 && err != nil {
+				t.Errorf("expected the retry to succeed, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package ocgorm
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"go.opencensus.io/trace"
+)
+
+// Rows wraps *sql.Rows and traces the iteration of the result set (see TraceRows).
+type Rows struct {
+	*sql.Rows
+
+	c     *callbacks
+	span  *trace.Span
+	start time.Time
+	count int64
+	ended bool
+}
+
+// TraceRows returns a wrapper of rows (eg. returned by gorm.DB.Rows) tracing the iteration of the result set
+// in a gorm:rows span, so the time spent streaming large result sets becomes visible.
+//
+// The span is ended when the iteration finishes (Next returns false) or the rows are closed,
+// whichever comes first, and records the number of iterated rows and the iteration time.
+// Options (eg. AllowRoot, DisableTrace) configure the span the same way as in RegisterCallbacks.
+func TraceRows(ctx context.Context, rows *sql.Rows, opts ...Option) *Rows {
+	c := newCallbacks(opts...)
+
+	r := &Rows{
+		Rows:  rows,
+		c:     c,
+		start: time.Now(),
+	}
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if !c.disableTrace && (trace.FromContext(ctx) != nil || c.allowRoot) {
+		_, r.span = trace.StartSpan(ctx, "gorm:rows", trace.WithSpanKind(c.spanKind), trace.WithSampler(c.spanSampler()))
+
+		r.span.AddAttributes(c.defaultAttributes...)
+	}
+
+	return r
+}
+
+// Next prepares the next result row (see sql.Rows.Next).
+func (r *Rows) Next() bool {
+	if r.Rows.Next() {
+		r.count++
+
+		return true
+	}
+
+	r.end()
+
+	return false
+}
+
+// Close closes the rows and ends the span. It is safe to call Close multiple times.
+func (r *Rows) Close() error {
+	err := r.Rows.Close()
+
+	r.end()
+
+	return err
+}
+
+// end ends the span of the rows once.
+func (r *Rows) end() {
+	if r.ended {
+		return
+	}
+
+	r.ended = true
+
+	if r.span == nil {
+		return
+	}
+
+	r.span.AddAttributes(
+		trace.Int64Attribute(RowsIteratedAttribute, r.count),
+		trace.StringAttribute("duration", time.Since(r.start).String()),
+	)
+
+	if err := r.Rows.Err(); err != nil {
+		r.span.SetStatus(r.c.statusFromError(err))
+	}
+
+	r.span.End()
+}
@@ -0,0 +1,168 @@
+package ocgorm
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+	"go.opencensus.io/trace"
+)
+
+// StartSpan instruments database code not covered by the callbacks (eg. queries executed using database/sql).
+// The span and the stats follow the same conventions as the ones of the callbacks.
+//
+// The returned function must be called with the result of the operation to end the span and record the stats.
+//
+//	ctx, end := ocgorm.StartSpan(ctx, "query", "people")
+//	rows, err := db.DB().QueryContext(ctx, "SELECT * FROM people")
+//	end(err)
+//
+// Options (eg. AllowRoot, DisableStats) configure the instrumentation the same way as in RegisterCallbacks,
+// with the exception of options depending on the gorm scope (eg. Filter, FormatSpanName).
+// Spans are always OpenCensus spans.
+func StartSpan(ctx context.Context, operation string, table string, opts ...Option) (context.Context, func(err error)) {
+	return StartQuerySpan(ctx, operation, table, "", opts...)
+}
+
+// StartQuerySpan is the same as StartSpan, but it also records the query in the span
+// (according to the Query, QueryOnError, ObfuscateQuery and MaxQueryLength options).
+func StartQuerySpan(ctx context.Context, operation string, table string, query string, opts ...Option) (context.Context, func(err error)) {
+	return newCallbacks(opts...).startSpan(ctx, operation, table, query)
+}
+
+func (c *callbacks) startSpan(ctx context.Context, operation string, table string, query string) (context.Context, func(err error)) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	// Probe traffic must not pollute the telemetry
+	if isHealthCheck(ctx) {
+		return ctx, func(err error) {}
+	}
+
+	var span *trace.Span
+
+	if !c.disableTrace && (trace.FromContext(ctx) != nil || c.allowRoot) {
+		ctx, span = c.startManualSpan(ctx, operation, table, query)
+	}
+
+	if !c.disableStats {
+		ctx = c.tagContext(ctx, operation, table)
+	}
+
+	start := time.Now()
+
+	return ctx, func(err error) {
+		if span != nil {
+			c.endManualSpan(span, query, err)
+		}
+
+		if !c.disableStats {
+			c.recordManualStats(ctx, time.Since(start), err)
+		}
+	}
+}
+
+func (c *callbacks) startManualSpan(ctx context.Context, operation string, table string, query string) (context.Context, *trace.Span) {
+	spanName := fmt.Sprintf("gorm:%s", operation)
+	if c.spanNameWithTable && table != "" {
+		spanName = fmt.Sprintf("gorm:%s %s", operation, table)
+	}
+
+	// Root spans are started from the context as well, so that its deadline and values are kept
+	ctx, span := trace.StartSpan(
+		ctx,
+		spanName,
+		trace.WithSpanKind(c.spanKind),
+		trace.WithSampler(c.spanSampler()),
+	)
+
+	attributes := make([]trace.Attribute, 0, len(c.defaultAttributes)+5)
+	attributes = append(attributes, c.defaultAttributes...)
+	attributes = append(
+		attributes,
+		trace.StringAttribute(OperationAttribute, operation),
+		trace.StringAttribute(TableAttribute, table),
+	)
+
+	if c.role != "" {
+		attributes = append(attributes, trace.StringAttribute(DBRoleAttribute, c.role))
+	}
+
+	if c.datadogCompat {
+		resource := spanName
+		if c.recordsQueries() && query != "" {
+			resource = c.processQuery(query)
+		}
+
+		attributes = append(
+			attributes,
+			trace.StringAttribute(ResourceNameAttribute, resource),
+			trace.StringAttribute(SpanTypeAttribute, "sql"),
+		)
+
+		if c.serviceName != "" {
+			attributes = append(attributes, trace.StringAttribute(ServiceNameAttribute, c.serviceName))
+		}
+	}
+
+	span.AddAttributes(attributes...)
+
+	return ctx, span
+}
+
+func (c *callbacks) endManualSpan(span *trace.Span, query string, err error) {
+	if query != "" && (c.recordsQueries() || (c.queryOnError && err != nil)) {
+		query = c.processQuery(query)
+
+		attributes := []trace.Attribute{
+			trace.StringAttribute(QueryAttribute, query),
+		}
+
+		if c.semanticAttributes {
+			attributes = append(attributes, trace.StringAttribute(DBStatementAttribute, query))
+		}
+
+		span.AddAttributes(attributes...)
+	}
+
+	if err != nil {
+		span.SetStatus(c.statusFromError(err))
+	}
+
+	span.End()
+}
+
+func (c *callbacks) recordManualStats(ctx context.Context, duration time.Duration, err error) {
+	notFound := gorm.IsRecordNotFoundError(err)
+	failed := err != nil && (!notFound || (c.countRecordNotFound && !c.recordNotFoundAsOK))
+
+	status := "OK"
+	if failed {
+		status = "ERROR"
+
+		if code, ok := contextErrorStatusCode(err); ok {
+			status = contextStatusTag(code)
+		}
+	}
+
+	ctx, _ = tag.New(ctx, tag.Upsert(Status, status))
+
+	measurements := []stats.Measurement{
+		c.measures.queryCount.M(1),
+		c.measures.latencyMs.M(float64(duration) / float64(time.Millisecond)),
+	}
+
+	if c.slowQueryThreshold > 0 && duration > c.slowQueryThreshold {
+		measurements = append(measurements, c.measures.slowQueryCount.M(1))
+	}
+
+	if failed {
+		measurements = append(measurements, c.measures.errorCount.M(1))
+	}
+
+	stats.Record(ctx, measurements...)
+}
@@ -0,0 +1,51 @@
+package ocgorm_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opencensus.io/trace"
+
+	"github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgorm"
+	"github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgorm/ocgormtest"
+)
+
+type contextKey struct{}
+
+func TestStartSpan_RootSpan(t *testing.T) {
+	exporter, unregister := ocgormtest.NewExporter()
+	defer unregister()
+
+	deadline := time.Now().Add(time.Minute)
+
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+
+	ctx = context.WithValue(ctx, contextKey{}, "value")
+
+	ctx, end := ocgorm.StartSpan(ctx, "query", "people", ocgorm.AllowRoot(true))
+
+	if trace.FromContext(ctx) == nil {
+		t.Fatal("expected a root span in the context")
+	}
+
+	if actual, ok := ctx.Deadline(); !ok || !actual.Equal(deadline) {
+		t.Error("expected the deadline of the context to be kept")
+	}
+
+	if actual := ctx.Value(contextKey{}); actual != "value" {
+		t.Errorf("expected the values of the context to be kept, got %v", actual)
+	}
+
+	end(nil)
+
+	spans := exporter.SpansWithName("gorm:query")
+	if len(spans) != 1 {
+		t.Fatalf("expected one exported span, got %d", len(spans))
+	}
+
+	if spans[0].ParentSpanID != (trace.SpanID{}) {
+		t.Error("expected a root span")
+	}
+}
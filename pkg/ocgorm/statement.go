@@ -0,0 +1,75 @@
+package ocgorm
+
+import (
+	"context"
+
+	"github.com/jinzhu/gorm"
+	"go.opencensus.io/trace"
+)
+
+// Gorm scope keys
+var (
+	statementSpanScopeKey = "_opencensusStatementSpan"
+)
+
+// StatementSpans records the statement executed by each operation as a gorm:statement child span of the operation span
+// with its own query (if enabled), table and number of affected rows.
+//
+// It implies WrapCallbacks (even if it is disabled explicitly): the operation span covers the whole callback chain,
+// including the operations saving associations (which are recorded as child spans with their own statements).
+// Otherwise the operation span would only cover the statement span.
+// Note that some statements (eg. the ones inserting into join tables of many to many associations) bypass the callbacks.
+type StatementSpans bool
+
+func (s StatementSpans) apply(c *callbacks) {
+	c.statementSpans = bool(s)
+}
+
+// registerStatementCallbacks registers callbacks starting a span around the gorm callback executing the statement of an operation.
+func (c *callbacks) registerStatementCallbacks(processor func() *gorm.CallbackProcessor, callback string) {
+	name := callback[len("gorm:"):]
+
+	processor().Before(callback).Register("instrumentation:before_"+name+"_statement", c.startStatement)
+	processor().After(callback).Register("instrumentation:after_"+name+"_statement", c.endStatement)
+}
+
+func (c *callbacks) startStatement(scope *gorm.Scope) {
+	if skip, _ := scope.Get(skipScopeKey); skip == true || c.disableTrace || c.tracer != nil {
+		return
+	}
+
+	rctx, _ := scope.Get(contextScopeKey)
+	ctx, ok := rctx.(context.Context)
+	if !ok || ctx == nil || trace.FromContext(ctx) == nil {
+		return
+	}
+
+	_, span := trace.StartSpan(ctx, "gorm:statement", trace.WithSpanKind(c.spanKind), trace.WithSampler(c.spanSampler()))
+
+	span.AddAttributes(c.defaultAttributes...)
+	span.AddAttributes(trace.StringAttribute(TableAttribute, scope.TableName()))
+
+	scope.InstanceSet(statementSpanScopeKey, span)
+}
+
+func (c *callbacks) endStatement(scope *gorm.Scope) {
+	rspan, _ := scope.InstanceGet(statementSpanScopeKey)
+	span, ok := rspan.(*trace.Span)
+	if !ok || span == nil {
+		return
+	}
+
+	scope.InstanceSet(statementSpanScopeKey, nil)
+
+	if c.recordsQuery(scope) && scope.SQL != "" {
+		span.AddAttributes(trace.StringAttribute(QueryAttribute, c.recordedQuery(scope)))
+	}
+
+	span.AddAttributes(trace.Int64Attribute(RowsAffectedAttribute, scope.DB().RowsAffected))
+
+	if scope.HasError() {
+		span.SetStatus(c.statusFromError(scope.DB().Error))
+	}
+
+	span.End()
+}
@@ -1,6 +1,7 @@
 package ocgorm
 
 import (
+	"go.opencensus.io/plugin/ochttp"
 	"go.opencensus.io/stats"
 	"go.opencensus.io/stats/view"
 	"go.opencensus.io/tag"
@@ -8,7 +9,32 @@ import (
 
 // Measures
 var (
-	QueryCount = stats.Int64("opencensus.io/gorm/query_count", "Number of queries started", stats.UnitDimensionless)
+	MeasureQueryCount     = stats.Int64("go.sql/client/calls", "The number of calls", stats.UnitDimensionless)
+	MeasureLatencyMs      = stats.Float64("go.sql/client/latency", "The latency of calls in milliseconds", stats.UnitMilliseconds)
+	MeasureErrorCount     = stats.Int64("go.sql/client/errors", "The number of errors", stats.UnitDimensionless)
+	MeasureRowsAffected   = stats.Int64("go.sql/client/rows_affected", "The number of rows affected by calls", stats.UnitDimensionless)
+	MeasureSlowQueryCount = stats.Int64("go.sql/client/slow_queries", "The number of slow calls", stats.UnitDimensionless)
+	MeasureRowsReturned   = stats.Int64("go.sql/client/rows_returned", "The number of rows returned by queries (excluding row queries)", stats.UnitDimensionless)
+	MeasureBatchSize      = stats.Int64("go.sql/client/batch_size", "The number of rows sent by create calls and multi-row INSERT statements", stats.UnitDimensionless)
+	MeasureNotFoundCount  = stats.Int64("go.sql/client/not_found", "The number of calls not finding any records", stats.UnitDimensionless)
+
+	MeasureRequestQueryCount   = stats.Int64("go.sql/client/request_calls", "The number of calls executed by a request", stats.UnitDimensionless)
+	MeasureUninstrumentedCount = stats.Int64("go.sql/client/uninstrumented_context", "The number of calls without a parent span (when root spans are not allowed)", stats.UnitDimensionless)
+
+	MeasureTransactionLatencyMs      = stats.Float64("go.sql/client/transaction/latency", "The duration of transactions in milliseconds", stats.UnitMilliseconds)
+	MeasureTransactionCommitCount    = stats.Int64("go.sql/client/transaction/commits", "The number of committed transactions", stats.UnitDimensionless)
+	MeasureTransactionRollbackCount  = stats.Int64("go.sql/client/transaction/rollbacks", "The number of rolled back transactions", stats.UnitDimensionless)
+	MeasureTransactionStatementCount = stats.Int64("go.sql/client/transaction/statements", "The number of statements executed in transactions", stats.UnitDimensionless)
+	MeasureTransactionLongCount      = stats.Int64("go.sql/client/transaction/long", "The number of transactions exceeding the long transaction threshold", stats.UnitDimensionless)
+	MeasureTransactionRetryCount     = stats.Int64("go.sql/client/transaction/retries", "The number of retried transactions (see WithRetry)", stats.UnitDimensionless)
+)
+
+// Deprecated measures kept for backwards compatibility
+var (
+	// QueryCount is the number of calls.
+	//
+	// Deprecated: use MeasureQueryCount instead.
+	QueryCount = MeasureQueryCount
 )
 
 // Tags applied to measures
@@ -18,14 +44,302 @@ var (
 
 	// Table name of the target database table
 	Table, _ = tag.NewKey("gorm.table")
+
+	// Status of the call (OK, ERROR, CANCELLED, DEADLINE_EXCEEDED, NOT_FOUND when EmptyUpdateAsNotFound is enabled)
+	Status, _ = tag.NewKey("sql.status")
+
+	// Database is the name of the database instance (see the DatabaseName option)
+	Database, _ = tag.NewKey("sql.instance")
+
+	// DatabaseRole is the role of the database (see the Role option)
+	DatabaseRole, _ = tag.NewKey("db.role")
+
+	// Statement is the name of the query (see WithQueryName) or the hash of its fingerprint (see the StatementTag option)
+	Statement, _ = tag.NewKey("sql.statement")
+
+	// TransactionOutcome is the outcome of a transaction (commit, rollback)
+	TransactionOutcome, _ = tag.NewKey("gorm.transaction.outcome")
+
+	// RollbackReason is the reason of a transaction rollback (explicit, error)
+	RollbackReason, _ = tag.NewKey("gorm.transaction.rollback_reason")
 )
 
 var (
+	SQLClientCallsView = &view.View{
+		Name:        "go.sql/client/calls",
+		Description: "The number of various calls",
+		TagKeys:     []tag.Key{Operation, Table, Database, Status},
+		Measure:     MeasureQueryCount,
+		Aggregation: view.Count(),
+	}
+
+	SQLClientLatencyView = &view.View{
+		Name:        "go.sql/client/latency",
+		Description: "The distribution of latencies of various calls in milliseconds",
+		TagKeys:     []tag.Key{Operation, Table, Database, Status},
+		Measure:     MeasureLatencyMs,
+		Aggregation: DefaultMillisecondsDistribution,
+	}
+
+	SQLClientErrorsView = &view.View{
+		Name:        "go.sql/client/errors",
+		Description: "The number of errors of various calls",
+		TagKeys:     []tag.Key{Operation, Table, Database, Status},
+		Measure:     MeasureErrorCount,
+		Aggregation: view.Count(),
+	}
+
+	SQLClientRowsAffectedView = &view.View{
+		Name:        "go.sql/client/rows_affected",
+		Description: "The distribution of rows affected by various calls",
+		TagKeys:     []tag.Key{Operation, Table, Database},
+		Measure:     MeasureRowsAffected,
+		Aggregation: DefaultRowsDistribution,
+	}
+
+	SQLClientSlowQueriesView = &view.View{
+		Name:        "go.sql/client/slow_queries",
+		Description: "The number of slow calls",
+		TagKeys:     []tag.Key{Operation, Table, Database},
+		Measure:     MeasureSlowQueryCount,
+		Aggregation: view.Count(),
+	}
+
+	SQLClientRowsReturnedView = &view.View{
+		Name:        "go.sql/client/rows_returned",
+		Description: "The distribution of rows returned by various queries",
+		TagKeys:     []tag.Key{Operation, Table, Database},
+		Measure:     MeasureRowsReturned,
+		Aggregation: DefaultRowsDistribution,
+	}
+
+	SQLClientBatchSizeView = &view.View{
+		Name:        "go.sql/client/batch_size",
+		Description: "The distribution of rows sent by create calls and multi-row INSERT statements",
+		TagKeys:     []tag.Key{Table, Database},
+		Measure:     MeasureBatchSize,
+		Aggregation: DefaultRowsDistribution,
+	}
+
+	SQLClientNotFoundView = &view.View{
+		Name:        "go.sql/client/not_found",
+		Description: "The number of calls not finding any records",
+		TagKeys:     []tag.Key{Operation, Table, Database},
+		Measure:     MeasureNotFoundCount,
+		Aggregation: view.Count(),
+	}
+
+	SQLClientUninstrumentedView = &view.View{
+		Name:        "go.sql/client/uninstrumented_context",
+		Description: "The number of calls not traced due to a missing parent span",
+		TagKeys:     []tag.Key{Operation, Table, Database},
+		Measure:     MeasureUninstrumentedCount,
+		Aggregation: view.Count(),
+	}
+
+	SQLClientRequestCallsView = &view.View{
+		Name:        "go.sql/client/request_calls",
+		Description: "The distribution of calls executed by requests",
+		TagKeys:     []tag.Key{ochttp.KeyServerRoute},
+		Measure:     MeasureRequestQueryCount,
+		Aggregation: DefaultRowsDistribution,
+	}
+
+	SQLClientCallsByStatementView = &view.View{
+		Name:        "go.sql/client/calls_by_statement",
+		Description: "The number of various calls by statement",
+		TagKeys:     []tag.Key{Operation, Table, Database, Status, Statement},
+		Measure:     MeasureQueryCount,
+		Aggregation: view.Count(),
+	}
+
+	SQLClientLatencyByStatementView = &view.View{
+		Name:        "go.sql/client/latency_by_statement",
+		Description: "The distribution of latencies of various calls in milliseconds by statement",
+		TagKeys:     []tag.Key{Operation, Table, Database, Status, Statement},
+		Measure:     MeasureLatencyMs,
+		Aggregation: DefaultMillisecondsDistribution,
+	}
+
+	SQLClientCallsByRouteView = &view.View{
+		Name:        "go.sql/client/calls_by_route",
+		Description: "The number of various calls by HTTP route",
+		TagKeys:     []tag.Key{Operation, Table, Database, Status, ochttp.KeyServerRoute},
+		Measure:     MeasureQueryCount,
+		Aggregation: view.Count(),
+	}
+
+	SQLClientLatencyByRouteView = &view.View{
+		Name:        "go.sql/client/latency_by_route",
+		Description: "The distribution of latencies of various calls in milliseconds by HTTP route",
+		TagKeys:     []tag.Key{Operation, Table, Database, Status, ochttp.KeyServerRoute},
+		Measure:     MeasureLatencyMs,
+		Aggregation: DefaultMillisecondsDistribution,
+	}
+
+	SQLClientTransactionLatencyView = &view.View{
+		Name:        "go.sql/client/transaction/latency",
+		Description: "The distribution of transaction durations in milliseconds",
+		TagKeys:     []tag.Key{Database, TransactionOutcome},
+		Measure:     MeasureTransactionLatencyMs,
+		Aggregation: DefaultMillisecondsDistribution,
+	}
+
+	SQLClientTransactionCommitsView = &view.View{
+		Name:        "go.sql/client/transaction/commits",
+		Description: "The number of committed transactions",
+		TagKeys:     []tag.Key{Database},
+		Measure:     MeasureTransactionCommitCount,
+		Aggregation: view.Count(),
+	}
+
+	SQLClientTransactionRollbacksView = &view.View{
+		Name:        "go.sql/client/transaction/rollbacks",
+		Description: "The number of rolled back transactions",
+		TagKeys:     []tag.Key{Database, RollbackReason},
+		Measure:     MeasureTransactionRollbackCount,
+		Aggregation: view.Count(),
+	}
+
+	SQLClientTransactionStatementsView = &view.View{
+		Name:        "go.sql/client/transaction/statements",
+		Description: "The distribution of statements executed in transactions",
+		TagKeys:     []tag.Key{Database, TransactionOutcome},
+		Measure:     MeasureTransactionStatementCount,
+		Aggregation: DefaultStatementsDistribution,
+	}
+
+	SQLClientLongTransactionsView = &view.View{
+		Name:        "go.sql/client/transaction/long",
+		Description: "The number of long running transactions",
+		TagKeys:     []tag.Key{Database, TransactionOutcome},
+		Measure:     MeasureTransactionLongCount,
+		Aggregation: view.Count(),
+	}
+
+	SQLClientTransactionRetriesView = &view.View{
+		Name:        "go.sql/client/transaction/retries",
+		Description: "The number of retried transactions",
+		TagKeys:     []tag.Key{Database},
+		Measure:     MeasureTransactionRetryCount,
+		Aggregation: view.Count(),
+	}
+)
+
+// Deprecated views kept for backwards compatibility
+var (
+	// QueryCountView is the number of calls exported under its original name.
+	//
+	// Deprecated: use SQLClientCallsView instead.
 	QueryCountView = &view.View{
 		Name:        "opencensus.io/gorm/query_count",
 		Description: "Count of queries started",
 		TagKeys:     []tag.Key{Operation, Table},
-		Measure:     QueryCount,
+		Measure:     MeasureQueryCount,
 		Aggregation: view.Count(),
 	}
 )
+
+// QueryViews contains the views recommended to register for query stats.
+var QueryViews = []*view.View{
+	SQLClientCallsView,
+	SQLClientLatencyView,
+	SQLClientErrorsView,
+	SQLClientRowsAffectedView,
+	SQLClientSlowQueriesView,
+	SQLClientRowsReturnedView,
+	SQLClientNotFoundView,
+	SQLClientUninstrumentedView,
+	SQLClientBatchSizeView,
+}
+
+// StatementViews contains the views including the Statement tag (see the StatementTag option).
+var StatementViews = []*view.View{
+	SQLClientCallsByStatementView,
+	SQLClientLatencyByStatementView,
+}
+
+// RouteViews contains the views including the route of the HTTP request executing the calls.
+// The route is read from the tags of the context (see ochttp.WithRouteTag and Route).
+var RouteViews = []*view.View{
+	SQLClientCallsByRouteView,
+	SQLClientLatencyByRouteView,
+}
+
+// TransactionViews contains the views recommended to register for transaction stats.
+var TransactionViews = []*view.View{
+	SQLClientTransactionLatencyView,
+	SQLClientTransactionCommitsView,
+	SQLClientTransactionRollbacksView,
+	SQLClientTransactionStatementsView,
+	SQLClientTransactionRetriesView,
+	SQLClientLongTransactionsView,
+}
+
+// RequestViews contains the views recommended to register for request stats (see WithRequestStats).
+var RequestViews = []*view.View{
+	SQLClientRequestCallsView,
+}
+
+// DefaultViews contains the views recommended to register for query, transaction, request and connection pool stats.
+var DefaultViews = append(append(append(append([]*view.View{}, QueryViews...), TransactionViews...), RequestViews...), ConnectionViews...)
+
+// RegisterAllViews registers DefaultViews.
+func RegisterAllViews() error {
+	return view.Register(DefaultViews...)
+}
+
+// Default distributions used by views in this package
+var (
+	DefaultMillisecondsDistribution = view.Distribution(
+		0.0,
+		0.001,
+		0.005,
+		0.01,
+		0.05,
+		0.1,
+		0.5,
+		1.0,
+		1.5,
+		2.0,
+		2.5,
+		5.0,
+		10.0,
+		25.0,
+		50.0,
+		100.0,
+		200.0,
+		400.0,
+		600.0,
+		800.0,
+		1000.0,
+		1500.0,
+		2000.0,
+		2500.0,
+		5000.0,
+		10000.0,
+		20000.0,
+		40000.0,
+		100000.0,
+		200000.0,
+		500000.0,
+	)
+
+	DefaultRowsDistribution = view.Distribution(1, 2, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000)
+
+	DefaultStatementsDistribution = view.Distribution(1, 2, 5, 10, 25, 50, 100)
+)
+
+// ViewsWithTagKeys returns copies of the views with additional tag keys (eg. the ones used in DefaultTags).
+func ViewsWithTagKeys(views []*view.View, keys ...tag.Key) []*view.View {
+	result := make([]*view.View, 0, len(views))
+
+	for _, v := range views {
+		vc := *v
+		vc.TagKeys = append(append([]tag.Key{}, v.TagKeys...), keys...)
+
+		result = append(result, &vc)
+	}
+
+	return result
+}
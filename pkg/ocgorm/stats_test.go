@@ -0,0 +1,376 @@
+package ocgorm_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+
+	"github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgorm"
+	"github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgorm/ocgormtest"
+)
+
+func TestLatency(t *testing.T) {
+	defer registerViews(t, ocgorm.SQLClientLatencyView)()
+
+	db := newTestDB(t, ocgorm.AllowRoot(true))
+
+	var people []Person
+
+	if err := db.Find(&people).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := ocgormtest.ViewRows(
+		ocgorm.SQLClientLatencyView,
+		tag.Tag{Key: ocgorm.Operation, Value: ocgorm.OpQuery},
+		tag.Tag{Key: ocgorm.Table, Value: "people"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) != 1 {
+		t.Fatalf("expected one row, got %d", len(rows))
+	}
+
+	data, ok := rows[0].Data.(*view.DistributionData)
+	if !ok {
+		t.Fatalf("expected distribution data, got %T", rows[0].Data)
+	}
+
+	if data.Count != 1 {
+		t.Errorf("expected one measurement, got %d", data.Count)
+	}
+
+	if data.Sum() <= 0 {
+		t.Errorf("expected a non-zero latency sum, got %f", data.Sum())
+	}
+}
+
+// slowHookDuration is the duration of the BeforeCreate hook of SlowHook.
+const slowHookDuration = 20 * time.Millisecond
+
+type SlowHook struct {
+	ID   uint
+	Name string
+}
+
+func (s *SlowHook) BeforeCreate() error {
+	time.Sleep(slowHookDuration)
+
+	return nil
+}
+
+func TestLatency_WrapCallbacks(t *testing.T) {
+	defer registerViews(t, ocgorm.SQLClientLatencyView)()
+
+	db := newTestDB(t, ocgorm.AllowRoot(true), ocgorm.WrapCallbacks(true))
+
+	if err := db.AutoMigrate(&SlowHook{}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Create(&SlowHook{Name: "John"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := ocgormtest.ViewRows(
+		ocgorm.SQLClientLatencyView,
+		tag.Tag{Key: ocgorm.Operation, Value: ocgorm.OpCreate},
+		tag.Tag{Key: ocgorm.Table, Value: "slow_hooks"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) != 1 {
+		t.Fatalf("expected one row, got %d", len(rows))
+	}
+
+	data, ok := rows[0].Data.(*view.DistributionData)
+	if !ok {
+		t.Fatalf("expected distribution data, got %T", rows[0].Data)
+	}
+
+	if expected := float64(slowHookDuration / time.Millisecond); data.Sum() < expected {
+		t.Errorf("expected the latency to include the hook (at least %fms), got %fms", expected, data.Sum())
+	}
+}
+
+func TestCalls(t *testing.T) {
+	defer registerViews(t, ocgorm.SQLClientCallsView, ocgorm.QueryCountView)()
+
+	db := newTestDB(t, ocgorm.AllowRoot(true))
+
+	for _, name := range []string{"John", "Jane"} {
+		if err := db.Create(&Person{FirstName: name}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var people []Person
+
+	if err := db.Find(&people).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		view      *view.View
+		operation string
+		expected  int64
+	}{
+		{ocgorm.SQLClientCallsView, ocgorm.OpCreate, 2},
+		{ocgorm.SQLClientCallsView, ocgorm.OpQuery, 1},
+		{ocgorm.QueryCountView, ocgorm.OpCreate, 2},
+		{ocgorm.QueryCountView, ocgorm.OpQuery, 1},
+	}
+
+	for _, test := range tests {
+		rows, err := ocgormtest.ViewRows(
+			test.view,
+			tag.Tag{Key: ocgorm.Operation, Value: test.operation},
+			tag.Tag{Key: ocgorm.Table, Value: "people"},
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if actual := countRows(rows); actual != test.expected {
+			t.Errorf("%s: expected %d %s calls, got %d", test.view.Name, test.expected, test.operation, actual)
+		}
+	}
+}
+
+// countRows returns the sum of the counts of the rows of a count view.
+func countRows(rows []*view.Row) int64 {
+	var count int64
+
+	for _, row := range rows {
+		if data, ok := row.Data.(*view.CountData); ok {
+			count += data.Value
+		}
+	}
+
+	return count
+}
+
+func TestRowsAffected(t *testing.T) {
+	defer registerViews(t, ocgorm.SQLClientRowsAffectedView)()
+
+	db := newTestDB(t, ocgorm.AllowRoot(true))
+
+	for _, name := range []string{"John", "Jane", "Jack"} {
+		if err := db.Create(&Person{FirstName: name, LastName: "Doe"}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := db.Create(&Person{FirstName: "Jill", LastName: "Roe"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	result := db.Model(&Person{}).Where("last_name = ?", "Doe").Update("last_name", "Smith")
+	if result.Error != nil {
+		t.Fatal(result.Error)
+	}
+
+	if result.RowsAffected != 3 {
+		t.Fatalf("expected the update to affect 3 rows, got %d", result.RowsAffected)
+	}
+
+	rows, err := ocgormtest.ViewRows(
+		ocgorm.SQLClientRowsAffectedView,
+		tag.Tag{Key: ocgorm.Operation, Value: ocgorm.OpUpdate},
+		tag.Tag{Key: ocgorm.Table, Value: "people"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) != 1 {
+		t.Fatalf("expected one row, got %d", len(rows))
+	}
+
+	data, ok := rows[0].Data.(*view.DistributionData)
+	if !ok {
+		t.Fatalf("expected distribution data, got %T", rows[0].Data)
+	}
+
+	if data.Count != 1 || data.Sum() != 3 {
+		t.Errorf("expected a single update affecting 3 rows, got %d updates affecting %f rows", data.Count, data.Sum())
+	}
+}
+
+// viewExporter collects the exported view data.
+type viewExporter struct {
+	mu   sync.Mutex
+	data []*view.Data
+}
+
+func (e *viewExporter) ExportView(d *view.Data) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.data = append(e.data, d)
+}
+
+// rows returns the rows of the last export of a view.
+func (e *viewExporter) rows(name string) []*view.Row {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for i := len(e.data) - 1; i >= 0; i-- {
+		if e.data[i].View.Name == name {
+			return e.data[i].Rows
+		}
+	}
+
+	return nil
+}
+
+func TestRowsReturned(t *testing.T) {
+	defer registerViews(t, ocgorm.SQLClientRowsReturnedView)()
+
+	db := newTestDB(t, ocgorm.AllowRoot(true))
+
+	for _, name := range []string{"John", "Jane", "Jack"} {
+		if err := db.Create(&Person{FirstName: name}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var people []Person
+
+	if err := db.Find(&people).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// Row queries are not counted
+	var count int
+
+	if err := db.Model(&Person{}).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := ocgormtest.ViewRows(ocgorm.SQLClientRowsReturnedView)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) != 1 {
+		t.Fatalf("expected one row, got %d", len(rows))
+	}
+
+	if operation := rowTag(rows[0], ocgorm.Operation); operation != ocgorm.OpQuery {
+		t.Errorf("expected rows returned by %s operations only, got %s", ocgorm.OpQuery, operation)
+	}
+
+	data, ok := rows[0].Data.(*view.DistributionData)
+	if !ok {
+		t.Fatalf("expected distribution data, got %T", rows[0].Data)
+	}
+
+	if data.Count != 1 || data.Sum() != 3 {
+		t.Errorf("expected a single query returning 3 rows, got %d queries returning %f rows", data.Count, data.Sum())
+	}
+}
+
+// rowTag returns the value of a tag of a view row.
+func rowTag(row *view.Row, key tag.Key) string {
+	for _, t := range row.Tags {
+		if t.Key == key {
+			return t.Value
+		}
+	}
+
+	return ""
+}
+
+func TestDefaultTags(t *testing.T) {
+	service, _ := tag.NewKey("service")
+
+	views := ocgorm.ViewsWithTagKeys([]*view.View{ocgorm.SQLClientCallsView}, service)
+
+	defer registerViews(t, views...)()
+
+	exporter := &viewExporter{}
+
+	view.RegisterExporter(exporter)
+	defer view.UnregisterExporter(exporter)
+
+	view.SetReportingPeriod(10 * time.Millisecond)
+	defer view.SetReportingPeriod(0)
+
+	db := newTestDB(t, ocgorm.AllowRoot(true), ocgorm.DefaultTags{tag.Upsert(service, "people-api")})
+
+	if err := db.Create(&Person{FirstName: "John"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	expected := tag.Tag{Key: service, Value: "people-api"}
+
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		for _, row := range exporter.rows(ocgorm.SQLClientCallsView.Name) {
+			for _, rt := range row.Tags {
+				if rt == expected {
+					return
+				}
+			}
+		}
+	}
+
+	t.Errorf("no exported %s row has the %s tag", ocgorm.SQLClientCallsView.Name, service.Name())
+}
+
+func TestStatus(t *testing.T) {
+	defer registerViews(t, ocgorm.SQLClientCallsView, ocgorm.SQLClientLatencyView)()
+
+	db := newTestDB(t, ocgorm.AllowRoot(true))
+
+	var people []Person
+
+	if err := db.Find(&people).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Table("missing").Find(&people).Error; err == nil {
+		t.Fatal("expected the query of a missing table to fail")
+	}
+
+	tests := []struct {
+		table  string
+		status string
+	}{
+		{"people", "OK"},
+		{"missing", "ERROR"},
+	}
+
+	for _, test := range tests {
+		tags := []tag.Tag{
+			{Key: ocgorm.Operation, Value: ocgorm.OpQuery},
+			{Key: ocgorm.Table, Value: test.table},
+			{Key: ocgorm.Status, Value: test.status},
+		}
+
+		calls, err := ocgormtest.ViewRows(ocgorm.SQLClientCallsView, tags...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if actual := countRows(calls); actual != 1 {
+			t.Errorf("expected one %s call, got %d", test.status, actual)
+		}
+
+		latency, err := ocgormtest.ViewRows(ocgorm.SQLClientLatencyView, tags...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(latency) != 1 {
+			t.Errorf("expected the latency of the %s call to be recorded", test.status)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package ocgorm
+
+import (
+	"context"
+	"database/sql/driver"
+
+	"github.com/jinzhu/gorm"
+	"go.opencensus.io/trace"
+)
+
+// ErrorClassifier maps an error to a trace status code.
+// It returns false if it cannot classify the error.
+//
+// Dialect specific classifiers can be found in the dialects subpackages.
+type ErrorClassifier func(err error) (int32, bool)
+
+// errorStatus returns the span status for the error of the current operation.
+func (c *callbacks) errorStatus(scope *gorm.Scope) trace.Status {
+	if status, ok := panicStatus(scope); ok {
+		return status
+	}
+
+	err := scope.DB().Error
+
+	if c.errorToStatus != nil {
+		roperation, _ := scope.Get(operationScopeKey)
+		operation, _ := roperation.(string)
+
+		if status := c.errorToStatus(operation, scope, err); status != nil {
+			return *status
+		}
+	}
+
+	if code, ok := contextStatusCode(scope, err); ok {
+		return trace.Status{Code: code, Message: err.Error()}
+	}
+
+	return c.statusFromError(err)
+}
+
+// contextStatusCode returns the status code of an error caused by the cancellation (or the deadline) of the context.
+//
+// Drivers do not always return the context error (eg. the MySQL driver returns mysql.ErrInvalidConn),
+// so the context of the operation is checked as well, but only for errors drivers return after a cancellation:
+// unrelated errors (eg. a constraint violation) of operations whose context is done by the time they end keep their status.
+func contextStatusCode(scope *gorm.Scope, err error) (int32, bool) {
+	if err == nil {
+		return 0, false
+	}
+
+	if code, ok := contextErrorStatusCode(err); ok {
+		return code, true
+	}
+
+	if !isConnectionError(err) {
+		return 0, false
+	}
+
+	rctx, _ := scope.Get(contextScopeKey)
+	ctx, ok := rctx.(context.Context)
+	if !ok || ctx == nil {
+		return 0, false
+	}
+
+	return contextErrorStatusCode(ctx.Err())
+}
+
+// contextErrorStatusCode returns the status code of context errors (even if they are wrapped).
+func contextErrorStatusCode(err error) (int32, bool) {
+	for err != nil {
+		switch err {
+		case context.Canceled:
+			return trace.StatusCodeCancelled, true
+
+		case context.DeadlineExceeded:
+			return trace.StatusCodeDeadlineExceeded, true
+		}
+
+		wrapper, ok := err.(interface{ Unwrap() error })
+		if !ok {
+			return 0, false
+		}
+
+		err = wrapper.Unwrap()
+	}
+
+	return 0, false
+}
+
+// invalidConnMessage is the message of mysql.ErrInvalidConn.
+// The error is matched by its message so that the driver is not imported.
+const invalidConnMessage = "invalid connection"
+
+// isConnectionError reports whether the error (or one of the errors it wraps) is a broken connection error,
+// that drivers return when the context of an operation is cancelled.
+func isConnectionError(err error) bool {
+	for err != nil {
+		if err == driver.ErrBadConn || err.Error() == invalidConnMessage {
+			return true
+		}
+
+		wrapper, ok := err.(interface{ Unwrap() error })
+		if !ok {
+			return false
+		}
+
+		err = wrapper.Unwrap()
+	}
+
+	return false
+}
+
+// statusFromError maps an error returned by gorm to a trace status.
+func (c *callbacks) statusFromError(err error) trace.Status {
+	status := trace.Status{
+		Code:    trace.StatusCodeUnknown,
+		Message: err.Error(),
+	}
+
+	if gorm.IsRecordNotFoundError(err) {
+		if c.recordNotFoundAsOK {
+			return trace.Status{}
+		}
+
+		status.Code = trace.StatusCodeNotFound
+
+		return status
+	}
+
+	// Classify the primary error when gorm accumulated multiple errors
+	if errs, ok := err.(gorm.Errors); ok && len(errs) > 0 {
+		err = errs[0]
+		status.Message = err.Error()
+	}
+
+	if code, ok := contextErrorStatusCode(err); ok {
+		status.Code = code
+
+		return status
+	}
+
+	if err == driver.ErrBadConn {
+		status.Code = trace.StatusCodeUnavailable
+
+		return status
+	}
+
+	for _, classify := range c.errorClassifiers {
+		if code, ok := classify(err); ok {
+			status.Code = code
+
+			break
+		}
+	}
+
+	return status
+}
+
+// errorAnnotations records each error in the span when gorm accumulated multiple errors.
+func errorAnnotations(span Span, err error) {
+	errs, ok := err.(gorm.Errors)
+	if !ok || len(errs) < 2 {
+		return
+	}
+
+	for _, e := range errs {
+		span.Annotate([]Attribute{StringAttribute("error", e.Error())}, "Error")
+	}
+}
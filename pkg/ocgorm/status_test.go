@@ -0,0 +1,120 @@
+package ocgorm
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jinzhu/gorm"
+	"go.opencensus.io/trace"
+)
+
+// wrappedError wraps an error the way drivers supporting error wrapping do.
+type wrappedError struct {
+	err error
+}
+
+func (e wrappedError) Error() string { return fmt.Sprintf("wrapped: %v", e.err) }
+func (e wrappedError) Unwrap() error { return e.err }
+
+// classifyMySQLError is a minimal MySQL classifier (the real one lives in the mysql dialect package).
+func classifyMySQLError(err error) (int32, bool) {
+	if merr, ok := err.(*mysql.MySQLError); ok && merr.Number == 1062 {
+		return trace.StatusCodeAlreadyExists, true
+	}
+
+	return 0, false
+}
+
+func TestStatusFromError(t *testing.T) {
+	duplicate := &mysql.MySQLError{Number: 1062, Message: "Duplicate entry"}
+
+	tests := []struct {
+		name    string
+		opts    []Option
+		err     error
+		code    int32
+		message string
+	}{
+		{"record not found", nil, gorm.ErrRecordNotFound, trace.StatusCodeNotFound, "record not found"},
+		{"record not found as ok", []Option{RecordNotFoundAsOK(true)}, gorm.ErrRecordNotFound, trace.StatusCodeOK, ""},
+		{"bad connection", nil, driver.ErrBadConn, trace.StatusCodeUnavailable, driver.ErrBadConn.Error()},
+		{"canceled", nil, context.Canceled, trace.StatusCodeCancelled, context.Canceled.Error()},
+		{"wrapped deadline", nil, wrappedError{context.DeadlineExceeded}, trace.StatusCodeDeadlineExceeded, "wrapped: context deadline exceeded"},
+		{"unclassified driver error", nil, duplicate, trace.StatusCodeUnknown, duplicate.Error()},
+		{"classified driver error", []Option{ErrorClassifiers(classifyMySQLError)}, duplicate, trace.StatusCodeAlreadyExists, duplicate.Error()},
+		{
+			"multiple errors",
+			[]Option{ErrorClassifiers(classifyMySQLError)},
+			gorm.Errors{duplicate, errors.New("second")},
+			trace.StatusCodeAlreadyExists,
+			duplicate.Error(),
+		},
+		{"other error", nil, errors.New("error"), trace.StatusCodeUnknown, "error"},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			status := newCallbacks(test.opts...).statusFromError(test.err)
+
+			if status.Code != test.code {
+				t.Errorf("expected status code %d, got %d", test.code, status.Code)
+			}
+
+			if status.Message != test.message {
+				t.Errorf("expected status message %q, got %q", test.message, status.Message)
+			}
+		})
+	}
+}
+
+func TestErrorStatus_Context(t *testing.T) {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	duplicate := &mysql.MySQLError{Number: 1062, Message: "Duplicate entry"}
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		err  error
+		code int32
+	}{
+		{"bad connection after cancel", canceled, driver.ErrBadConn, trace.StatusCodeCancelled},
+		{"invalid connection after deadline", expired, mysql.ErrInvalidConn, trace.StatusCodeDeadlineExceeded},
+		{"wrapped bad connection after cancel", canceled, wrappedError{driver.ErrBadConn}, trace.StatusCodeCancelled},
+		{"bad connection", context.Background(), driver.ErrBadConn, trace.StatusCodeUnavailable},
+		{"unrelated error after cancel", canceled, duplicate, trace.StatusCodeUnknown},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			scope := db.NewScope(nil)
+			scope.Set(contextScopeKey, test.ctx)
+			_ = scope.Err(test.err)
+
+			status := newCallbacks().errorStatus(scope)
+
+			if status.Code != test.code {
+				t.Errorf("expected status code %d, got %d", test.code, status.Code)
+			}
+		})
+	}
+}
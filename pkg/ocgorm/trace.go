@@ -2,6 +2,79 @@ package ocgorm
 
 // Attributes recorded on the span for the queries.
 const (
-	QueryAttribute = "gorm.query"
-	TableAttribute = "gorm.table"
+	OperationAttribute = "gorm.operation"
+	QueryAttribute     = "gorm.query"
+	QueryVarsAttribute = "gorm.query.vars"
+	TableAttribute     = "gorm.table"
+	TablesAttribute    = "gorm.tables"
+	PreloadAttribute   = "gorm.preload"
+
+	RowsAffectedAttribute = "gorm.rows_affected"
+	RowsIteratedAttribute = "gorm.rows.iterated"
+
+	BatchRowsAttribute   = "gorm.batch.rows"
+	BatchTuplesAttribute = "gorm.batch.tuples"
+
+	QueryFingerprintAttribute = "gorm.query.fingerprint"
+	QueryHashAttribute        = "gorm.query.hash"
+)
+
+// Connection pool attributes recorded on the span for the queries (see the PoolStatsAttributes option).
+const (
+	PoolOpenConnectionsAttribute  = "sql.conns.open"
+	PoolInUseConnectionsAttribute = "sql.conns.in_use"
+	PoolWaitCountAttribute        = "sql.conns.wait_count"
 )
+
+// HTTPRouteAttribute is the route of the HTTP request executing the query
+// (recorded when the context carries the ochttp.KeyServerRoute tag).
+const HTTPRouteAttribute = "http.route"
+
+// Attributes recorded on the span for association operations (see WrapAssociation).
+const (
+	AssociationAttribute      = "gorm.association"
+	AssociationTableAttribute = "gorm.association.table"
+)
+
+// Attributes recorded on the span for transactions.
+const (
+	TransactionOutcomeAttribute = "gorm.transaction.outcome"
+	RetryAttemptAttribute       = "gorm.retry.attempt"
+)
+
+// DBRoleAttribute is the role of the database recorded on the span (see the Role option).
+const DBRoleAttribute = "db.role"
+
+// OpenTelemetry semantic convention attributes recorded on the span for the queries.
+//
+// See https://github.com/open-telemetry/opentelemetry-specification/blob/master/specification/trace/semantic_conventions/database.md
+const (
+	DBSystemAttribute    = "db.system"
+	DBNameAttribute      = "db.name"
+	DBStatementAttribute = "db.statement"
+	NetPeerNameAttribute = "net.peer.name"
+	NetPeerPortAttribute = "net.peer.port"
+)
+
+// Datadog attributes recorded on the span for the queries (see the DatadogCompat option).
+//
+// Datadog APM aggregates database spans by these attributes.
+const (
+	ResourceNameAttribute = "resource.name"
+	SpanTypeAttribute     = "span.type"
+	ServiceNameAttribute  = "service.name"
+)
+
+// dbSystem converts a gorm dialect name to a db.system attribute value.
+func dbSystem(dialect string) string {
+	switch dialect {
+	case "postgres":
+		return "postgresql"
+
+	case "sqlite3":
+		return "sqlite"
+
+	default:
+		return dialect
+	}
+}
@@ -0,0 +1,510 @@
+package ocgorm_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/tag"
+	"go.opencensus.io/trace"
+
+	"github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgorm"
+	"github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgorm/ocgormtest"
+)
+
+func TestFormatSpanName(t *testing.T) {
+	db := newTestDB(
+		t,
+		ocgorm.AllowRoot(true),
+		ocgorm.FormatSpanName(func(operation string, scope *gorm.Scope) string {
+			if operation != ocgorm.OpQuery {
+				return ""
+			}
+
+			return "find " + scope.TableName()
+		}),
+	)
+
+	exporter, unregister := ocgormtest.NewExporter()
+	defer unregister()
+
+	if err := db.Create(&Person{FirstName: "John"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var people []Person
+
+	if err := db.Find(&people).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if spans := exporter.SpansWithName("find people"); len(spans) != 1 {
+		t.Errorf("expected one span with the custom name, got %d", len(spans))
+	}
+
+	// Empty names fall back to the default name
+	if spans := exporter.SpansWithName("gorm:create"); len(spans) != 1 {
+		t.Errorf("expected one span with the default name, got %d", len(spans))
+	}
+}
+
+// findSpan returns the single exported span with the given name and table.
+func findSpan(t *testing.T, exporter *ocgormtest.Exporter, name string, table string) *trace.SpanData {
+	t.Helper()
+
+	var found []*trace.SpanData
+
+	for _, span := range exporter.SpansWithName(name) {
+		if span.Attributes[ocgorm.TableAttribute] == table {
+			found = append(found, span)
+		}
+	}
+
+	if len(found) != 1 {
+		t.Fatalf("expected one %s span of table %s, got %d", name, table, len(found))
+	}
+
+	return found[0]
+}
+
+func TestPreload(t *testing.T) {
+	db := newTestDB(t)
+
+	if err := db.Create(&Author{Name: "John", Books: []Book{{Title: "First"}, {Title: "Second"}}}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	exporter, unregister := ocgormtest.NewExporter()
+	defer unregister()
+
+	ctx, span := trace.StartSpan(context.Background(), "parent")
+
+	var authors []Author
+
+	if err := ocgorm.WithContext(ctx, db).Preload("Books").Find(&authors).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	span.End()
+
+	query := findSpan(t, exporter, "gorm:query", "authors")
+	preload := findSpan(t, exporter, "gorm:query", "books")
+
+	if query.ParentSpanID != span.SpanContext().SpanID {
+		t.Error("the query span is not a child of the parent span")
+	}
+
+	if preload.ParentSpanID != query.SpanID {
+		t.Error("the preload span is not a child of the query span")
+	}
+
+	if preload.Attributes[ocgorm.PreloadAttribute] != true {
+		t.Errorf("expected the %s attribute on the preload span", ocgorm.PreloadAttribute)
+	}
+}
+
+func TestSaveAssociations(t *testing.T) {
+	db := newTestDB(t)
+
+	exporter, unregister := ocgormtest.NewExporter()
+	defer unregister()
+
+	ctx, span := trace.StartSpan(context.Background(), "parent")
+
+	author := Author{Name: "John", Books: []Book{{Title: "First"}}}
+
+	if err := ocgorm.WithContext(ctx, db).Create(&author).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	span.End()
+
+	create := findSpan(t, exporter, "gorm:create", "authors")
+	association := findSpan(t, exporter, "gorm:create", "books")
+
+	if association.ParentSpanID != create.SpanID {
+		t.Error("the span saving the association is not a child of the create span")
+	}
+}
+
+func TestSpanKind_ChildSpan(t *testing.T) {
+	db := newTestDB(t)
+
+	exporter, unregister := ocgormtest.NewExporter()
+	defer unregister()
+
+	ctx, span := trace.StartSpan(context.Background(), "parent")
+
+	if err := ocgorm.WithContext(ctx, db).Create(&Person{FirstName: "John"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	span.End()
+
+	create := findSpan(t, exporter, "gorm:create", "people")
+
+	if create.ParentSpanID != span.SpanContext().SpanID {
+		t.Error("the create span is not a child of the parent span")
+	}
+
+	if create.SpanKind != trace.SpanKindClient {
+		t.Errorf("expected span kind %d, got %d", trace.SpanKindClient, create.SpanKind)
+	}
+}
+
+func TestStartOptions_ChildSpan(t *testing.T) {
+	db := newTestDB(t, ocgorm.StartOptions(trace.StartOptions{Sampler: trace.NeverSample()}))
+
+	exporter, unregister := ocgormtest.NewExporter()
+	defer unregister()
+
+	ctx, span := trace.StartSpan(context.Background(), "parent")
+
+	if err := ocgorm.WithContext(ctx, db).Create(&Person{FirstName: "John"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	span.End()
+
+	if spans := exporter.SpansWithName("gorm:create"); len(spans) != 0 {
+		t.Errorf("expected the sampler of the start options to apply to child spans, got %d spans", len(spans))
+	}
+}
+
+func TestRelated(t *testing.T) {
+	db := newTestDB(t)
+
+	author := Author{Name: "John", Books: []Book{{Title: "First"}, {Title: "Second"}}}
+
+	if err := db.Create(&author).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	exporter, unregister := ocgormtest.NewExporter()
+	defer unregister()
+
+	ctx, span := trace.StartSpan(context.Background(), "parent")
+
+	var books []Book
+
+	if err := ocgorm.WithContext(ctx, db).Model(&author).Related(&books).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	span.End()
+
+	if len(books) != 2 {
+		t.Fatalf("expected two books, got %d", len(books))
+	}
+
+	query := findSpan(t, exporter, "gorm:query", "books")
+
+	if query.ParentSpanID != span.SpanContext().SpanID {
+		t.Error("the query of the related records is not a child of the parent span")
+	}
+}
+
+func TestAssociation(t *testing.T) {
+	db := newTestDB(t)
+
+	author := Author{Name: "John", Books: []Book{{Title: "First"}, {Title: "Second"}}}
+
+	if err := db.Create(&author).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	exporter, unregister := ocgormtest.NewExporter()
+	defer unregister()
+
+	ctx, span := trace.StartSpan(context.Background(), "parent")
+
+	var books []Book
+
+	if err := ocgorm.WithContext(ctx, db).Model(&author).Association("Books").Find(&books).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	span.End()
+
+	if len(books) != 2 {
+		t.Fatalf("expected two books, got %d", len(books))
+	}
+
+	query := findSpan(t, exporter, "gorm:query", "books")
+
+	if query.ParentSpanID != span.SpanContext().SpanID {
+		t.Error("the query of the association is not a child of the parent span")
+	}
+}
+
+func TestSampler(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     []ocgorm.Option
+		expected int
+	}{
+		{"never sample", []ocgorm.Option{ocgorm.Sampler(trace.NeverSample())}, 0},
+		{
+			"precedence over start options",
+			[]ocgorm.Option{
+				ocgorm.Sampler(trace.NeverSample()),
+				ocgorm.StartOptions(trace.StartOptions{Sampler: trace.AlwaysSample()}),
+			},
+			0,
+		},
+		{
+			"start options",
+			[]ocgorm.Option{ocgorm.StartOptions(trace.StartOptions{Sampler: trace.AlwaysSample()})},
+			1,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			db := newTestDB(t, append(test.opts, ocgorm.AllowRoot(true))...)
+
+			exporter, unregister := ocgormtest.NewExporter()
+			defer unregister()
+
+			if err := db.Create(&Person{FirstName: "John"}).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			if spans := exporter.SpansWithName("gorm:create"); len(spans) != test.expected {
+				t.Errorf("expected %d exported spans, got %d", test.expected, len(spans))
+			}
+		})
+	}
+}
+
+func TestConcurrentOperations(t *testing.T) {
+	db := newTestDB(t)
+
+	exporter, unregister := ocgormtest.NewExporter()
+	defer unregister()
+
+	ctx, span := trace.StartSpan(context.Background(), "parent")
+
+	// A context-bound instance shared by every goroutine
+	shared := ocgorm.WithContext(ctx, db)
+
+	const goroutines = 10
+
+	var wg sync.WaitGroup
+
+	errs := make(chan error, goroutines*2)
+
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if err := shared.Create(&Person{FirstName: "John"}).Error; err != nil {
+				errs <- err
+			}
+
+			var people []Person
+
+			if err := shared.Find(&people).Error; err != nil {
+				errs <- err
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+
+	span.End()
+
+	for _, name := range []string{"gorm:create", "gorm:query"} {
+		spans := exporter.SpansWithName(name)
+		if len(spans) != goroutines {
+			t.Errorf("expected %d %s spans, got %d", goroutines, name, len(spans))
+		}
+
+		ids := make(map[trace.SpanID]bool)
+
+		for _, s := range spans {
+			if s.ParentSpanID != span.SpanContext().SpanID {
+				t.Errorf("the %s span is not a child of the parent span", name)
+			}
+
+			ids[s.SpanID] = true
+		}
+
+		// A span ended twice would be exported twice
+		if len(ids) != len(spans) {
+			t.Errorf("expected every %s span to be exported once", name)
+		}
+	}
+}
+
+func TestMultipleErrors(t *testing.T) {
+	db := newTestDB(t, ocgorm.AllowRoot(true))
+
+	db.Callback().Create().Before("gorm:create").Register("test:errors", func(scope *gorm.Scope) {
+		_ = scope.Err(errors.New("first"))
+		_ = scope.Err(errors.New("second"))
+	})
+
+	exporter, unregister := ocgormtest.NewExporter()
+	defer unregister()
+
+	if err := db.Create(&Person{FirstName: "John"}).Error; err == nil {
+		t.Fatal("expected an error")
+	}
+
+	span := findSpan(t, exporter, "gorm:create", "people")
+
+	if span.Status.Message != "first" {
+		t.Errorf("expected the status message of the first error, got %q", span.Status.Message)
+	}
+
+	var errs []interface{}
+
+	for _, annotation := range span.Annotations {
+		if annotation.Message == "Error" {
+			errs = append(errs, annotation.Attributes["error"])
+		}
+	}
+
+	if len(errs) != 2 || errs[0] != "first" || errs[1] != "second" {
+		t.Errorf("expected an annotation for each error, got %v", errs)
+	}
+}
+
+func TestHTTPRouteAttribute(t *testing.T) {
+	db := newTestDB(t)
+
+	exporter, unregister := ocgormtest.NewExporter()
+	defer unregister()
+
+	ctx, span := trace.StartSpan(context.Background(), "parent")
+	defer span.End()
+
+	routeCtx, err := tag.New(ctx, tag.Upsert(ochttp.KeyServerRoute, "/people/:id"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var people []Person
+
+	if err := ocgorm.WithContext(routeCtx, db).Find(&people).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	spans := exporter.SpansWithName("gorm:query")
+	if len(spans) != 1 {
+		t.Fatalf("expected one query span, got %d", len(spans))
+	}
+
+	if actual := spans[0].Attributes[ocgorm.HTTPRouteAttribute]; actual != "/people/:id" {
+		t.Errorf("expected the route attribute, got %v", actual)
+	}
+
+	exporter.Reset()
+
+	// Nothing is added without the route tag
+	if err := ocgorm.WithContext(ctx, db).Find(&people).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	spans = exporter.SpansWithName("gorm:query")
+	if len(spans) != 1 {
+		t.Fatalf("expected one query span, got %d", len(spans))
+	}
+
+	if actual, ok := spans[0].Attributes[ocgorm.HTTPRouteAttribute]; ok {
+		t.Errorf("unexpected route attribute %v", actual)
+	}
+}
+
+func TestSpanAttributes(t *testing.T) {
+	db := newTestDB(
+		t,
+		ocgorm.AllowRoot(true),
+		ocgorm.DefaultAttributes{trace.StringAttribute("db", "test")},
+		ocgorm.SpanAttributes{ocgorm.StringAttribute("tenant", "acme"), ocgorm.Int64Attribute("shard", 3)},
+		ocgorm.SpanModifier(func(ctx context.Context, span ocgorm.Span, scope *gorm.Scope) {
+			span.AddAttributes(ocgorm.BoolAttribute("modified", true), ocgorm.Float64Attribute("weight", 0.5))
+		}),
+	)
+
+	exporter, unregister := ocgormtest.NewExporter()
+	defer unregister()
+
+	if err := db.Create(&Person{FirstName: "John"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	span := findSpan(t, exporter, "gorm:create", "people")
+
+	expected := map[string]interface{}{
+		"db":       "test",
+		"tenant":   "acme",
+		"shard":    int64(3),
+		"modified": true,
+		"weight":   0.5,
+	}
+
+	for key, value := range expected {
+		if actual := span.Attributes[key]; actual != value {
+			t.Errorf("expected %s attribute %v, got %v", key, value, actual)
+		}
+	}
+}
+
+func TestStatementSpans(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []ocgorm.Option
+	}{
+		{"statement spans", []ocgorm.Option{ocgorm.StatementSpans(true)}},
+		{"wrap callbacks disabled after", []ocgorm.Option{ocgorm.StatementSpans(true), ocgorm.WrapCallbacks(false)}},
+		{"wrap callbacks disabled before", []ocgorm.Option{ocgorm.WrapCallbacks(false), ocgorm.StatementSpans(true)}},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			db := newTestDB(t, test.opts...)
+
+			exporter, unregister := ocgormtest.NewExporter()
+			defer unregister()
+
+			ctx, span := trace.StartSpan(context.Background(), "parent")
+
+			author := Author{Name: "John", Books: []Book{{Title: "First"}}}
+
+			if err := ocgorm.WithContext(ctx, db).Create(&author).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			span.End()
+
+			create := findSpan(t, exporter, "gorm:create", "authors")
+			statement := findSpan(t, exporter, "gorm:statement", "authors")
+			association := findSpan(t, exporter, "gorm:create", "books")
+
+			if statement.ParentSpanID != create.SpanID {
+				t.Error("the statement span is not a child of the operation span")
+			}
+
+			// The operation span covers saving the associations (see WrapCallbacks)
+			if association.ParentSpanID != create.SpanID {
+				t.Error("the association span is not a child of the operation span")
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package ocgorm
+
+import (
+	"context"
+
+	"go.opencensus.io/trace"
+)
+
+// Tracer starts the spans of the operations instead of OpenCensus (see WithTracer).
+//
+// It allows emitting spans using other tracing libraries (see the ocgormotel package for OpenTelemetry).
+type Tracer interface {
+	// HasSpan reports whether the context contains a span the started spans would be children of.
+	HasSpan(ctx context.Context) bool
+
+	// StartSpan starts a span as a child of the span in the context (if any).
+	// The options contain the kind and the sampler (if any) of the span.
+	StartSpan(ctx context.Context, name string, o trace.StartOptions) (context.Context, Span)
+}
+
+// Span is a span of an operation started by a Tracer.
+//
+// OpenCensus spans are adapted to it when the spans are not started by a Tracer.
+type Span interface {
+	AddAttributes(attributes ...Attribute)
+	Annotate(attributes []Attribute, str string)
+	SetStatus(status trace.Status)
+	End()
+}
+
+// Attribute is an attribute recorded in the span of an operation.
+//
+// Unlike OpenCensus attributes, it exposes its key and its value (a bool, an int64, a float64 or a string),
+// so that tracers can convert it.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// BoolAttribute returns a bool-valued attribute.
+func BoolAttribute(key string, value bool) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Int64Attribute returns an int64-valued attribute.
+func Int64Attribute(key string, value int64) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Float64Attribute returns a float64-valued attribute.
+func Float64Attribute(key string, value float64) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// StringAttribute returns a string-valued attribute.
+func StringAttribute(key string, value string) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// ocAttributes converts attributes to OpenCensus attributes.
+func ocAttributes(attributes []Attribute) []trace.Attribute {
+	if len(attributes) == 0 {
+		return nil
+	}
+
+	ocAttributes := make([]trace.Attribute, 0, len(attributes))
+
+	for _, a := range attributes {
+		switch v := a.Value.(type) {
+		case bool:
+			ocAttributes = append(ocAttributes, trace.BoolAttribute(a.Key, v))
+
+		case int64:
+			ocAttributes = append(ocAttributes, trace.Int64Attribute(a.Key, v))
+
+		case float64:
+			ocAttributes = append(ocAttributes, trace.Float64Attribute(a.Key, v))
+
+		case string:
+			ocAttributes = append(ocAttributes, trace.StringAttribute(a.Key, v))
+		}
+	}
+
+	return ocAttributes
+}
+
+// ocSpan adapts an OpenCensus span to Span.
+type ocSpan struct {
+	*trace.Span
+}
+
+func (s ocSpan) AddAttributes(attributes ...Attribute) {
+	s.Span.AddAttributes(ocAttributes(attributes)...)
+}
+
+func (s ocSpan) Annotate(attributes []Attribute, str string) {
+	s.Span.Annotate(ocAttributes(attributes), str)
+}
+
+// WithTracer makes the callbacks start the spans of the operations using the tracer instead of OpenCensus.
+// Stats are still recorded using OpenCensus.
+//
+// Options configuring the spans of the operations (eg. SpanAttributes, SpanModifier, Sampler, Logger)
+// apply to the spans of the tracer the same way.
+// OpenCensus attributes do not expose their keys and values, so DefaultAttributes are not recorded in the spans of the tracer:
+// use SpanAttributes instead.
+// Additional spans (eg. of model hooks, statements or the driver) are always OpenCensus spans.
+func WithTracer(t Tracer) Option {
+	return OptionFunc(func(c *callbacks) {
+		c.tracer = t
+	})
+}
@@ -0,0 +1,268 @@
+package ocgorm
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+	"go.opencensus.io/trace"
+)
+
+// Gorm scope keys
+var (
+	transactionScopeKey      = "_opencensusTransaction"
+	transactionStartScopeKey = "_opencensusTransactionStart"
+	transactionCountScopeKey = "_opencensusTransactionCount"
+)
+
+// Transaction outcomes
+const (
+	TransactionCommit   = "commit"
+	TransactionRollback = "rollback"
+)
+
+// Rollback reasons
+const (
+	RollbackExplicit = "explicit"
+	RollbackError    = "error"
+)
+
+// Begin starts a transaction on a database instance bound to a context (see WithContext).
+//
+// A gorm:transaction span is started (following the AllowRoot configuration of the callbacks)
+// as the parent of the operations executed in the transaction.
+// The span is ended and the transaction stats are recorded by Commit or Rollback.
+func Begin(db *gorm.DB) *gorm.DB {
+	c := callbacksFromDB(db)
+
+	rctx, _ := db.Get(contextScopeKey)
+	ctx, ok := rctx.(context.Context)
+	if !ok || ctx == nil {
+		ctx = context.Background()
+	}
+
+	var span *trace.Span
+
+	if !c.disableTrace && (trace.FromContext(ctx) != nil || c.allowRoot) {
+		ctx, span = trace.StartSpan(
+			ctx,
+			"gorm:transaction",
+			trace.WithSpanKind(c.spanKind),
+			trace.WithSampler(c.spanSampler()),
+		)
+
+		span.AddAttributes(c.defaultAttributes...)
+	}
+
+	tx := db.Set(contextScopeKey, ctx).Begin()
+
+	if span != nil {
+		if tx.Error != nil {
+			span.SetStatus(c.statusFromError(tx.Error))
+			span.End()
+
+			return tx
+		}
+
+		tx.InstantSet(transactionScopeKey, span)
+	}
+
+	if tx.Error == nil {
+		tx.InstantSet(transactionStartScopeKey, time.Now())
+		tx.InstantSet(transactionCountScopeKey, new(int64))
+	}
+
+	return tx
+}
+
+// Transaction runs fn in a transaction started by Begin on db bound to ctx.
+//
+// The transaction is committed if fn returns nil, otherwise it is rolled back
+// and the span status is set according to the returned error.
+// If fn panics, the transaction is rolled back and the panic is propagated.
+func Transaction(ctx context.Context, db *gorm.DB, fn func(tx *gorm.DB) error) error {
+	tx := Begin(WithContext(ctx, db))
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			Rollback(tx, fmt.Errorf("panic: %v", r))
+
+			panic(r)
+		}
+	}()
+
+	err := fn(tx)
+	if err != nil {
+		Rollback(tx, err)
+
+		return err
+	}
+
+	return Commit(tx).Error
+}
+
+// Commit commits a transaction started by Begin and ends the transaction span.
+func Commit(tx *gorm.DB) *gorm.DB {
+	tx = tx.Commit()
+
+	endTransaction(tx, TransactionCommit, tx.Error)
+
+	return tx
+}
+
+// Rollback rolls back a transaction started by Begin and ends the transaction span.
+//
+// If err is not nil, it is recorded as the reason of the rollback and the span status is set accordingly.
+func Rollback(tx *gorm.DB, err error) *gorm.DB {
+	tx = tx.Rollback()
+
+	if tx.Error != nil {
+		err = tx.Error
+	}
+
+	endTransaction(tx, TransactionRollback, err)
+
+	return tx
+}
+
+func endTransaction(tx *gorm.DB, outcome string, err error) {
+	c := callbacksFromDB(tx)
+
+	duration, long := c.longTransaction(tx)
+
+	if !c.disableStats {
+		c.recordTransactionStats(tx, outcome, err, long)
+	}
+
+	if long && c.onLongTransaction != nil {
+		c.onLongTransaction(transactionContext(tx), duration)
+	}
+
+	rspan, _ := tx.Get(transactionScopeKey)
+	span, ok := rspan.(*trace.Span)
+	if !ok || span == nil {
+		return
+	}
+
+	span.AddAttributes(trace.StringAttribute(TransactionOutcomeAttribute, outcome))
+
+	if long {
+		span.Annotate(
+			[]trace.Attribute{trace.StringAttribute("duration", duration.String())},
+			"Long running transaction",
+		)
+	}
+
+	if err != nil {
+		span.SetStatus(c.statusFromError(err))
+	}
+
+	span.End()
+}
+
+// recordTransactionStats records the duration and the outcome of a transaction.
+//
+// A failed commit is recorded as a rollback caused by an error.
+func (c *callbacks) recordTransactionStats(tx *gorm.DB, outcome string, err error, long bool) {
+	rstart, _ := tx.Get(transactionStartScopeKey)
+	start, ok := rstart.(time.Time)
+	if !ok {
+		return
+	}
+
+	ctx := transactionContext(tx)
+
+	if outcome == TransactionCommit && err != nil {
+		outcome = TransactionRollback
+	}
+
+	mutators := append(
+		c.defaultTags[:len(c.defaultTags):len(c.defaultTags)],
+		tag.Upsert(TransactionOutcome, outcome),
+	)
+
+	if c.databaseName != "" {
+		mutators = append(mutators, tag.Upsert(Database, c.databaseName))
+	}
+
+	if c.role != "" {
+		mutators = append(mutators, tag.Upsert(DatabaseRole, c.role))
+	}
+
+	var measurements []stats.Measurement
+
+	if outcome == TransactionCommit {
+		measurements = append(measurements, c.measures.txCommitCount.M(1))
+	} else {
+		reason := RollbackExplicit
+		if err != nil {
+			reason = RollbackError
+		}
+
+		mutators = append(mutators, tag.Upsert(RollbackReason, reason))
+		measurements = append(measurements, c.measures.txRollbackCount.M(1))
+	}
+
+	latency := float64(time.Since(start)) / float64(time.Millisecond)
+	measurements = append(measurements, c.measures.txLatencyMs.M(latency))
+
+	if rcount, _ := tx.Get(transactionCountScopeKey); rcount != nil {
+		if count, ok := rcount.(*int64); ok {
+			measurements = append(measurements, c.measures.txStatementCount.M(atomic.LoadInt64(count)))
+		}
+	}
+
+	if long {
+		measurements = append(measurements, c.measures.txLongCount.M(1))
+	}
+
+	ctx, _ = tag.New(ctx, mutators...)
+
+	stats.Record(ctx, measurements...)
+}
+
+// transactionContext returns the context bound to a transaction started by Begin.
+func transactionContext(tx *gorm.DB) context.Context {
+	rctx, _ := tx.Get(contextScopeKey)
+	ctx, ok := rctx.(context.Context)
+	if !ok || ctx == nil {
+		return context.Background()
+	}
+
+	return ctx
+}
+
+// longTransaction returns the duration of a transaction started by Begin if it exceeds the long transaction threshold.
+func (c *callbacks) longTransaction(tx *gorm.DB) (time.Duration, bool) {
+	if c.longTransactionThreshold <= 0 {
+		return 0, false
+	}
+
+	rstart, _ := tx.Get(transactionStartScopeKey)
+	start, ok := rstart.(time.Time)
+	if !ok {
+		return 0, false
+	}
+
+	duration := time.Since(start)
+	if duration <= c.longTransactionThreshold {
+		return 0, false
+	}
+
+	return duration, true
+}
+
+// countTransactionStatement counts the statement of the scope if it is executed in a transaction started by Begin.
+func countTransactionStatement(scope *gorm.Scope) {
+	rcount, _ := scope.Get(transactionCountScopeKey)
+	if count, ok := rcount.(*int64); ok {
+		atomic.AddInt64(count, 1)
+	}
+}
@@ -0,0 +1,252 @@
+package ocgorm_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"go.opencensus.io/trace"
+
+	"github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgorm"
+	"github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgorm/ocgormtest"
+)
+
+// transactionSpan returns the single exported transaction span.
+func transactionSpan(t *testing.T, exporter *ocgormtest.Exporter) *trace.SpanData {
+	t.Helper()
+
+	spans := exporter.SpansWithName("gorm:transaction")
+	if len(spans) != 1 {
+		t.Fatalf("expected one transaction span, got %d", len(spans))
+	}
+
+	return spans[0]
+}
+
+// latencyCount returns the number of transactions recorded in the latency view with the outcome.
+func latencyCount(t *testing.T, outcome string) int64 {
+	t.Helper()
+
+	rows, err := ocgormtest.ViewRows(ocgorm.SQLClientTransactionLatencyView, tag.Tag{Key: ocgorm.TransactionOutcome, Value: outcome})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var count int64
+
+	for _, row := range rows {
+		if data, ok := row.Data.(*view.DistributionData); ok {
+			count += data.Count
+		}
+	}
+
+	return count
+}
+
+func TestCommit(t *testing.T) {
+	db := newTestDB(t)
+
+	defer registerViews(t, ocgorm.TransactionViews...)()
+
+	exporter, unregister := ocgormtest.NewExporter()
+	defer unregister()
+
+	ctx, parent := trace.StartSpan(context.Background(), "parent")
+
+	tx := ocgorm.Begin(ocgorm.WithContext(ctx, db))
+	if tx.Error != nil {
+		t.Fatal(tx.Error)
+	}
+
+	if err := tx.Create(&Person{FirstName: "John", LastName: "Doe"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ocgorm.Commit(tx).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	parent.End()
+
+	var count int
+	if err := db.Model(&Person{}).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 1 {
+		t.Errorf("expected the transaction to be committed, got %d records", count)
+	}
+
+	txSpan := transactionSpan(t, exporter)
+
+	if txSpan.ParentSpanID != parent.SpanContext().SpanID {
+		t.Error("the transaction span is not a child of the parent span")
+	}
+
+	if actual := txSpan.Attributes[ocgorm.TransactionOutcomeAttribute]; actual != ocgorm.TransactionCommit {
+		t.Errorf("expected the %s outcome, got %v", ocgorm.TransactionCommit, actual)
+	}
+
+	if txSpan.Status.Code != trace.StatusCodeOK {
+		t.Errorf("expected an OK status, got %v", txSpan.Status)
+	}
+
+	span := findSpan(t, exporter, "gorm:create", "people")
+
+	if span.ParentSpanID != txSpan.SpanID {
+		t.Error("the statement span is not a child of the transaction span")
+	}
+
+	rows, err := ocgormtest.ViewRows(ocgorm.SQLClientTransactionCommitsView)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if actual := countRows(rows); actual != 1 {
+		t.Errorf("expected one commit, got %d", actual)
+	}
+
+	rows, err = ocgormtest.ViewRows(ocgorm.SQLClientTransactionRollbacksView)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if actual := countRows(rows); actual != 0 {
+		t.Errorf("expected no rollbacks, got %d", actual)
+	}
+
+	if actual := latencyCount(t, ocgorm.TransactionCommit); actual != 1 {
+		t.Errorf("expected the latency of one committed transaction, got %d", actual)
+	}
+}
+
+func TestRollback(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		reason string
+		status int32
+	}{
+		{
+			name:   "explicit",
+			reason: ocgorm.RollbackExplicit,
+			status: trace.StatusCodeOK,
+		},
+		{
+			name:   "error",
+			err:    errors.New("failure"),
+			reason: ocgorm.RollbackError,
+			status: trace.StatusCodeUnknown,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+
+		t.Run(test.name, func(t *testing.T) {
+			db := newTestDB(t)
+
+			defer registerViews(t, ocgorm.TransactionViews...)()
+
+			exporter, unregister := ocgormtest.NewExporter()
+			defer unregister()
+
+			ctx, parent := trace.StartSpan(context.Background(), "parent")
+
+			tx := ocgorm.Begin(ocgorm.WithContext(ctx, db))
+			if tx.Error != nil {
+				t.Fatal(tx.Error)
+			}
+
+			if err := tx.Create(&Person{FirstName: "John", LastName: "Doe"}).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			if err := ocgorm.Rollback(tx, test.err).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			parent.End()
+
+			var count int
+			if err := db.Model(&Person{}).Count(&count).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			if count != 0 {
+				t.Errorf("expected the transaction to be rolled back, got %d records", count)
+			}
+
+			txSpan := transactionSpan(t, exporter)
+
+			if txSpan.ParentSpanID != parent.SpanContext().SpanID {
+				t.Error("the transaction span is not a child of the parent span")
+			}
+
+			if actual := txSpan.Attributes[ocgorm.TransactionOutcomeAttribute]; actual != ocgorm.TransactionRollback {
+				t.Errorf("expected the %s outcome, got %v", ocgorm.TransactionRollback, actual)
+			}
+
+			if txSpan.Status.Code != test.status {
+				t.Errorf("expected status code %d, got %v", test.status, txSpan.Status)
+			}
+
+			span := findSpan(t, exporter, "gorm:create", "people")
+
+			if span.ParentSpanID != txSpan.SpanID {
+				t.Error("the statement span is not a child of the transaction span")
+			}
+
+			rows, err := ocgormtest.ViewRows(ocgorm.SQLClientTransactionRollbacksView, tag.Tag{Key: ocgorm.RollbackReason, Value: test.reason})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if actual := countRows(rows); actual != 1 {
+				t.Errorf("expected one %s rollback, got %d", test.reason, actual)
+			}
+
+			rows, err = ocgormtest.ViewRows(ocgorm.SQLClientTransactionCommitsView)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if actual := countRows(rows); actual != 0 {
+				t.Errorf("expected no commits, got %d", actual)
+			}
+
+			if actual := latencyCount(t, ocgorm.TransactionRollback); actual != 1 {
+				t.Errorf("expected the latency of one rolled back transaction, got %d", actual)
+			}
+		})
+	}
+}
+
+func TestBegin_NoParentSpan(t *testing.T) {
+	db := newTestDB(t)
+
+	defer registerViews(t, ocgorm.TransactionViews...)()
+
+	exporter, unregister := ocgormtest.NewExporter()
+	defer unregister()
+
+	tx := ocgorm.Begin(ocgorm.WithContext(context.Background(), db))
+	if tx.Error != nil {
+		t.Fatal(tx.Error)
+	}
+
+	if err := ocgorm.Commit(tx).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if spans := exporter.SpansWithName("gorm:transaction"); len(spans) != 0 {
+		t.Errorf("expected no transaction span without a parent span, got %d", len(spans))
+	}
+
+	// Stats are recorded regardless of tracing
+	if actual := latencyCount(t, ocgorm.TransactionCommit); actual != 1 {
+		t.Errorf("expected the latency of one committed transaction, got %d", actual)
+	}
+}
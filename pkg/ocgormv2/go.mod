@@ -0,0 +1,12 @@
+module github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgormv2
+
+go 1.14
+
+require (
+	github.com/mattn/go-sqlite3 v1.10.0
+	github.com/sagikazarmark/go-gin-gorm-opencensus v0.0.0
+	go.opencensus.io v0.21.0
+	gorm.io/gorm v1.20.12
+)
+
+replace github.com/sagikazarmark/go-gin-gorm-opencensus => ../..
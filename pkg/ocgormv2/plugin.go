@@ -0,0 +1,376 @@
+// Package ocgormv2 provides OpenCensus instrumentation for GORM v2 (gorm.io/gorm).
+//
+// It records the core measures, tags and span attributes of the ocgorm package:
+// call counts, latencies, errors, not found records, rows affected and returned, slow queries,
+// and the database instance (see DatabaseName) and role (see Role).
+//
+// The following ocgorm features are not supported:
+// namespaced measures (ocgorm.Namespace), query verb operations (ocgorm.OperationFromSQL),
+// query fingerprint attributes (ocgorm.QueryFingerprint), statement tags (ocgorm.StatementTag),
+// query obfuscation and error classification.
+//
+// Since GORM v2 supports contexts natively, the context is taken from the statement
+// (see gorm.DB.WithContext) instead of ocgorm.WithContext.
+// The span of each operation is set in the context of the statement while the operation runs,
+// so that hooks and context aware drivers can start child spans.
+//
+// The package is a separate module, so that GORM v2 is not a dependency of the ocgorm package.
+package ocgormv2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+	"go.opencensus.io/trace"
+	"gorm.io/gorm"
+
+	"github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgorm"
+)
+
+// Gorm instance keys
+var (
+	spanInstanceKey      = "_opencensusSpan"
+	ctxInstanceKey       = "_opencensusContext"
+	parentCtxInstanceKey = "_opencensusParentContext"
+	startInstanceKey     = "_opencensusStart"
+)
+
+// Option allows for managing ocgormv2 configuration using functional options.
+type Option interface {
+	apply(p *plugin)
+}
+
+// OptionFunc converts a regular function to an Option if it's definition is compatible.
+type OptionFunc func(p *plugin)
+
+func (fn OptionFunc) apply(p *plugin) {
+	fn(p)
+}
+
+// AllowRoot allows creating root spans in the absence of existing spans.
+type AllowRoot bool
+
+func (a AllowRoot) apply(p *plugin) {
+	p.allowRoot = bool(a)
+}
+
+// Query allows recording the sql queries in spans.
+type Query bool
+
+func (q Query) apply(p *plugin) {
+	p.query = bool(q)
+}
+
+// StartOptions configures the initial options applied to a span.
+func StartOptions(o trace.StartOptions) Option {
+	return OptionFunc(func(p *plugin) {
+		p.startOptions = o
+	})
+}
+
+// SlowQueryThreshold sets the duration above which queries are considered slow.
+// Slow queries are annotated in spans and counted in a separate measure (see ocgorm.MeasureSlowQueryCount).
+// Zero disables slow query detection.
+type SlowQueryThreshold time.Duration
+
+func (s SlowQueryThreshold) apply(p *plugin) {
+	p.slowQueryThreshold = time.Duration(s)
+}
+
+// DatabaseName sets the database instance name recorded with each measurement (see ocgorm.DatabaseName).
+type DatabaseName string
+
+func (d DatabaseName) apply(p *plugin) {
+	p.databaseName = string(d)
+}
+
+// Role sets the role of the database (eg. primary, replica) recorded as a span attribute and with each measurement
+// (see ocgorm.Role).
+type Role string
+
+func (r Role) apply(p *plugin) {
+	p.role = string(r)
+}
+
+// DefaultAttributes sets attributes to each span.
+type DefaultAttributes []trace.Attribute
+
+func (d DefaultAttributes) apply(p *plugin) {
+	p.defaultAttributes = []trace.Attribute(d)
+}
+
+type plugin struct {
+	// Allow ocgormv2 to create root spans absence of existing spans or even context.
+	// Default is to not trace ocgormv2 calls if no existing parent span is found
+	// in context.
+	allowRoot bool
+
+	// Allow recording of sql queries in spans.
+	// Only allow this if it is safe to have queries recorded with respect to
+	// security.
+	query bool
+
+	// startOptions are applied to the span started around each request.
+	//
+	// StartOptions.SpanKind will always be set to trace.SpanKindClient.
+	startOptions trace.StartOptions
+
+	// DefaultAttributes will be set to each span as default.
+	defaultAttributes []trace.Attribute
+
+	// Duration above which queries are considered slow.
+	// Zero disables slow query detection.
+	slowQueryThreshold time.Duration
+
+	// Name of the database instance recorded with each measurement.
+	databaseName string
+
+	// Role of the database recorded as a span attribute and with each measurement.
+	role string
+}
+
+// NewPlugin returns a gorm plugin registering the necessary callbacks for instrumentation.
+//
+//	db.Use(ocgormv2.NewPlugin())
+func NewPlugin(opts ...Option) gorm.Plugin {
+	p := &plugin{}
+
+	for _, opt := range opts {
+		opt.apply(p)
+	}
+
+	return p
+}
+
+func (p *plugin) Name() string {
+	return "opencensus"
+}
+
+func (p *plugin) Initialize(db *gorm.DB) error {
+	callback := db.Callback()
+
+	registrations := []struct {
+		register func(name string, fn func(*gorm.DB)) error
+		name     string
+		fn       func(*gorm.DB)
+	}{
+		{callback.Create().Before("*").Register, "instrumentation:before_create", p.before("create")},
+		{callback.Create().After("*").Register, "instrumentation:after_create", p.after},
+		{callback.Query().Before("*").Register, "instrumentation:before_query", p.before("query")},
+		{callback.Query().After("*").Register, "instrumentation:after_query", p.after},
+		{callback.Update().Before("*").Register, "instrumentation:before_update", p.before("update")},
+		{callback.Update().After("*").Register, "instrumentation:after_update", p.after},
+		{callback.Delete().Before("*").Register, "instrumentation:before_delete", p.before("delete")},
+		{callback.Delete().After("*").Register, "instrumentation:after_delete", p.after},
+		{callback.Row().Before("*").Register, "instrumentation:before_row", p.before("row")},
+		{callback.Row().After("*").Register, "instrumentation:after_row", p.after},
+		{callback.Raw().Before("*").Register, "instrumentation:before_raw", p.before("raw")},
+		{callback.Raw().After("*").Register, "instrumentation:after_raw", p.after},
+	}
+
+	for _, r := range registrations {
+		if err := r.register(r.name, r.fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *plugin) before(operation string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		ctx := db.Statement.Context
+		if ctx == nil {
+			ctx = context.Background()
+		}
+
+		db.InstanceSet(parentCtxInstanceKey, db.Statement.Context)
+
+		ctx = p.startTrace(ctx, db, operation)
+		ctx = p.startStats(ctx, db, operation)
+
+		// Expose the span to the driver (and to the hooks) through the statement
+		db.Statement.Context = ctx
+
+		db.InstanceSet(ctxInstanceKey, ctx)
+		db.InstanceSet(startInstanceKey, time.Now())
+	}
+}
+
+func (p *plugin) after(db *gorm.DB) {
+	p.endTrace(db)
+	p.endStats(db)
+
+	// Restore the context of the caller, so that chained operations are not parented by an ended span
+	if rctx, ok := db.InstanceGet(parentCtxInstanceKey); ok {
+		ctx, _ := rctx.(context.Context)
+
+		db.Statement.Context = ctx
+	}
+}
+
+func (p *plugin) startTrace(ctx context.Context, db *gorm.DB, operation string) context.Context {
+	parentSpan := trace.FromContext(ctx)
+	if parentSpan == nil && !p.allowRoot {
+		return ctx
+	}
+
+	startOptions := []trace.StartOption{
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithSampler(p.startOptions.Sampler),
+	}
+
+	ctx, span := trace.StartSpan(ctx, fmt.Sprintf("gorm:%s", operation), startOptions...)
+
+	attributes := make([]trace.Attribute, 0, len(p.defaultAttributes)+3)
+	attributes = append(attributes, p.defaultAttributes...)
+	attributes = append(
+		attributes,
+		trace.StringAttribute(ocgorm.OperationAttribute, operation),
+		trace.StringAttribute(ocgorm.TableAttribute, db.Statement.Table),
+	)
+
+	if p.role != "" {
+		attributes = append(attributes, trace.StringAttribute(ocgorm.DBRoleAttribute, p.role))
+	}
+
+	span.AddAttributes(attributes...)
+
+	db.InstanceSet(spanInstanceKey, span)
+
+	return ctx
+}
+
+func (p *plugin) endTrace(db *gorm.DB) {
+	rspan, ok := db.InstanceGet(spanInstanceKey)
+	if !ok {
+		return
+	}
+
+	span, ok := rspan.(*trace.Span)
+	if !ok {
+		return
+	}
+
+	if p.query {
+		span.AddAttributes(trace.StringAttribute(ocgorm.QueryAttribute, db.Statement.SQL.String()))
+	}
+
+	if duration, ok := p.slowQuery(db); ok {
+		attributes := []trace.Attribute{
+			trace.StringAttribute("duration", duration.String()),
+		}
+
+		if p.query {
+			attributes = append(attributes, trace.StringAttribute("query", db.Statement.SQL.String()))
+		}
+
+		span.Annotate(attributes, "Slow query")
+	}
+
+	var status trace.Status
+
+	if db.Error != nil {
+		status.Code = trace.StatusCodeUnknown
+		if errors.Is(db.Error, gorm.ErrRecordNotFound) {
+			status.Code = trace.StatusCodeNotFound
+		}
+
+		status.Message = db.Error.Error()
+	}
+
+	span.SetStatus(status)
+
+	span.End()
+}
+
+// slowQuery returns the duration of the operation if it exceeds the slow query threshold.
+func (p *plugin) slowQuery(db *gorm.DB) (time.Duration, bool) {
+	if p.slowQueryThreshold <= 0 {
+		return 0, false
+	}
+
+	rstart, _ := db.InstanceGet(startInstanceKey)
+	start, ok := rstart.(time.Time)
+	if !ok {
+		return 0, false
+	}
+
+	duration := time.Since(start)
+	if duration <= p.slowQueryThreshold {
+		return 0, false
+	}
+
+	return duration, true
+}
+
+func (p *plugin) startStats(ctx context.Context, db *gorm.DB, operation string) context.Context {
+	mutators := []tag.Mutator{
+		tag.Upsert(ocgorm.Operation, operation),
+		tag.Upsert(ocgorm.Table, db.Statement.Table),
+	}
+
+	if p.databaseName != "" {
+		mutators = append(mutators, tag.Upsert(ocgorm.Database, p.databaseName))
+	}
+
+	if p.role != "" {
+		mutators = append(mutators, tag.Upsert(ocgorm.DatabaseRole, p.role))
+	}
+
+	ctx, _ = tag.New(ctx, mutators...)
+
+	return ctx
+}
+
+func (p *plugin) endStats(db *gorm.DB) {
+	rctx, _ := db.InstanceGet(ctxInstanceKey)
+	ctx, ok := rctx.(context.Context)
+	if !ok || ctx == nil {
+		return
+	}
+
+	notFound := errors.Is(db.Error, gorm.ErrRecordNotFound)
+	failed := db.Error != nil && !notFound
+
+	status := "OK"
+	if failed {
+		status = "ERROR"
+	}
+
+	ctx, _ = tag.New(ctx, tag.Upsert(ocgorm.Status, status))
+
+	measurements := []stats.Measurement{ocgorm.MeasureQueryCount.M(1)}
+
+	rstart, _ := db.InstanceGet(startInstanceKey)
+	if start, ok := rstart.(time.Time); ok {
+		latency := float64(time.Since(start)) / float64(time.Millisecond)
+
+		measurements = append(measurements, ocgorm.MeasureLatencyMs.M(latency))
+	}
+
+	if _, ok := p.slowQuery(db); ok {
+		measurements = append(measurements, ocgorm.MeasureSlowQueryCount.M(1))
+	}
+
+	if notFound {
+		measurements = append(measurements, ocgorm.MeasureNotFoundCount.M(1))
+	}
+
+	if failed {
+		measurements = append(measurements, ocgorm.MeasureErrorCount.M(1))
+	} else if db.Error == nil {
+		measurements = append(measurements, ocgorm.MeasureRowsAffected.M(db.RowsAffected))
+
+		// GORM sets RowsAffected to the number of scanned rows for queries
+		if operation, _ := tag.FromContext(ctx).Value(ocgorm.Operation); operation == "query" {
+			measurements = append(measurements, ocgorm.MeasureRowsReturned.M(db.RowsAffected))
+		}
+	}
+
+	stats.Record(ctx, measurements...)
+}
@@ -0,0 +1,285 @@
+package ocgormv2_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"go.opencensus.io/trace"
+	"gorm.io/gorm"
+	"gorm.io/gorm/callbacks"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+
+	"github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgorm"
+	"github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgorm/ocgormtest"
+	"github.com/sagikazarmark/go-gin-gorm-opencensus/pkg/ocgormv2"
+)
+
+// sqliteDialector is a minimal sqlite dialector for tests.
+type sqliteDialector struct {
+	conn *sql.DB
+}
+
+func (d sqliteDialector) Name() string {
+	return "sqlite"
+}
+
+func (d sqliteDialector) Initialize(db *gorm.DB) error {
+	callbacks.RegisterDefaultCallbacks(db, &callbacks.Config{LastInsertIDReversed: true})
+
+	db.ConnPool = d.conn
+
+	return nil
+}
+
+func (d sqliteDialector) Migrator(db *gorm.DB) gorm.Migrator {
+	return nil
+}
+
+func (d sqliteDialector) DataTypeOf(*schema.Field) string {
+	return ""
+}
+
+func (d sqliteDialector) DefaultValueOf(*schema.Field) clause.Expression {
+	return clause.Expr{SQL: "NULL"}
+}
+
+func (d sqliteDialector) BindVarTo(writer clause.Writer, stmt *gorm.Statement, v interface{}) {
+	_ = writer.WriteByte('?')
+}
+
+func (d sqliteDialector) QuoteTo(writer clause.Writer, str string) {
+	_ = writer.WriteByte('`')
+	_, _ = writer.WriteString(str)
+	_ = writer.WriteByte('`')
+}
+
+func (d sqliteDialector) Explain(sql string, vars ...interface{}) string {
+	return sql
+}
+
+type exporter struct {
+	mu    sync.Mutex
+	spans []*trace.SpanData
+}
+
+func (e *exporter) ExportSpan(s *trace.SpanData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.spans = append(e.spans, s)
+}
+
+func (e *exporter) spansWithName(name string) []*trace.SpanData {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var spans []*trace.SpanData
+
+	for _, span := range e.spans {
+		if span.Name == name {
+			spans = append(spans, span)
+		}
+	}
+
+	return spans
+}
+
+type Person struct {
+	ID        uint
+	FirstName string
+
+	// hookSpan is the span found in the context of the statement by the BeforeCreate hook
+	hookSpan *trace.Span
+}
+
+func (p *Person) BeforeCreate(tx *gorm.DB) error {
+	p.hookSpan = trace.FromContext(tx.Statement.Context)
+
+	return nil
+}
+
+func newTestDB(t *testing.T, opts ...ocgormv2.Option) *gorm.DB {
+	t.Helper()
+
+	conn, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every connection has its own in-memory database
+	conn.SetMaxOpenConns(1)
+
+	if _, err := conn.Exec("CREATE TABLE people (id INTEGER PRIMARY KEY, first_name TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := gorm.Open(sqliteDialector{conn: conn}, &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Use(ocgormv2.NewPlugin(opts...)); err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func TestPlugin_StatementContext(t *testing.T) {
+	db := newTestDB(t)
+
+	e := &exporter{}
+	trace.RegisterExporter(e)
+	defer trace.UnregisterExporter(e)
+
+	ctx, span := trace.StartSpan(context.Background(), "parent", trace.WithSampler(trace.AlwaysSample()))
+
+	person := Person{FirstName: "John"}
+
+	tx := db.WithContext(ctx)
+
+	if err := tx.Create(&person).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var people []Person
+
+	if err := tx.Find(&people).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	span.End()
+
+	creates := e.spansWithName("gorm:create")
+	if len(creates) != 1 {
+		t.Fatalf("expected one create span, got %d", len(creates))
+	}
+
+	if person.hookSpan == nil || person.hookSpan.SpanContext().SpanID != creates[0].SpanID {
+		t.Error("expected the statement context to carry the create span")
+	}
+
+	queries := e.spansWithName("gorm:query")
+	if len(queries) != 1 {
+		t.Fatalf("expected one query span, got %d", len(queries))
+	}
+
+	if queries[0].ParentSpanID != span.SpanContext().SpanID {
+		t.Error("expected chained operations to be parented by the caller span")
+	}
+}
+
+func TestPlugin_Stats(t *testing.T) {
+	db := newTestDB(t, ocgormv2.DatabaseName("main"), ocgormv2.Role("primary"), ocgormv2.SlowQueryThreshold(time.Nanosecond))
+
+	for _, name := range []string{"John", "Jane"} {
+		if err := db.Create(&Person{FirstName: name}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	views := []*view.View{ocgorm.SQLClientRowsReturnedView, ocgorm.SQLClientNotFoundView, ocgorm.SQLClientSlowQueriesView}
+
+	// Views are registered after the setup so that only the tested operations are recorded
+	if err := view.Register(views...); err != nil {
+		t.Fatal(err)
+	}
+	defer view.Unregister(views...)
+
+	e := &exporter{}
+	trace.RegisterExporter(e)
+	defer trace.UnregisterExporter(e)
+
+	ctx, span := trace.StartSpan(context.Background(), "parent", trace.WithSampler(trace.AlwaysSample()))
+
+	var people []Person
+
+	if err := db.WithContext(ctx).Find(&people).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var person Person
+
+	if err := db.WithContext(ctx).First(&person, 42).Error; !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expected a record not found error, got %v", err)
+	}
+
+	span.End()
+
+	tags := []tag.Tag{
+		{Key: ocgorm.Operation, Value: "query"},
+		{Key: ocgorm.Table, Value: "people"},
+		{Key: ocgorm.Database, Value: "main"},
+	}
+
+	tests := []struct {
+		view     *view.View
+		expected func(data view.AggregationData) bool
+	}{
+		{
+			view: ocgorm.SQLClientRowsReturnedView,
+			expected: func(data view.AggregationData) bool {
+				d, ok := data.(*view.DistributionData)
+
+				return ok && d.Count == 1 && d.Max == 2
+			},
+		},
+		{
+			view: ocgorm.SQLClientNotFoundView,
+			expected: func(data view.AggregationData) bool {
+				d, ok := data.(*view.CountData)
+
+				return ok && d.Value == 1
+			},
+		},
+		{
+			view: ocgorm.SQLClientSlowQueriesView,
+			expected: func(data view.AggregationData) bool {
+				d, ok := data.(*view.CountData)
+
+				return ok && d.Value == 2
+			},
+		},
+	}
+
+	for _, test := range tests {
+		rows, err := ocgormtest.ViewRows(test.view, tags...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(rows) != 1 {
+			t.Errorf("%s: expected one row, got %d", test.view.Name, len(rows))
+
+			continue
+		}
+
+		if !test.expected(rows[0].Data) {
+			t.Errorf("%s: unexpected data %v", test.view.Name, rows[0].Data)
+		}
+	}
+
+	queries := e.spansWithName("gorm:query")
+	if len(queries) != 2 {
+		t.Fatalf("expected two query spans, got %d", len(queries))
+	}
+
+	for _, query := range queries {
+		if actual := query.Attributes[ocgorm.DBRoleAttribute]; actual != "primary" {
+			t.Errorf("expected the database role attribute, got %v", actual)
+		}
+
+		if len(query.Annotations) != 1 || query.Annotations[0].Message != "Slow query" {
+			t.Errorf("expected a slow query annotation, got %v", query.Annotations)
+		}
+	}
+}